@@ -0,0 +1,78 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// accountIDPattern what an AWS account ID looks like
+var accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+
+// AccountOverride changes how alarms from one member account are handled when a single deployment notifies for the
+// whole organization, configured by the ACCOUNTS JSON object keyed by account ID.  Template and Channel apply when the
+// alarm's route doesn't name one and take precedence over the severity's.  Alarms whose name matches one of the
+// Suppress glob patterns aren't notified, and neither are OK notifications with SuppressOK.
+type AccountOverride struct {
+	Template   string   `json:"template,omitempty"`
+	Channel    string   `json:"channel,omitempty"`
+	Suppress   []string `json:"suppress,omitempty"`
+	SuppressOK bool     `json:"suppress_ok,omitempty"`
+}
+
+// parseAccountOverrides parses the ACCOUNTS setting
+func parseAccountOverrides(value string) (map[string]AccountOverride, error) {
+	parsed := map[string]AccountOverride{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, err
+	}
+	for account, override := range parsed {
+		if !accountIDPattern.MatchString(account) {
+			return nil, fmt.Errorf("%q isn't an account ID", account)
+		}
+		if override.Template != "" {
+			if _, ok := templates[override.Template]; !ok {
+				return nil, fmt.Errorf("account %s: unknown template %q", account, override.Template)
+			}
+		}
+		if err := validChannel(override.Channel); err != nil {
+			return nil, fmt.Errorf("account %s: %s", account, err)
+		}
+		for _, pattern := range override.Suppress {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("account %s: suppress pattern %q: %s", account, pattern, err)
+			}
+		}
+	}
+	return parsed, nil
+}
+
+// accountOverride the override for the alarm's account, empty when there isn't one
+func accountOverride(cloudWatchAlarmEvent CloudWatchAlarmEvent) AccountOverride {
+	return accountOverrides[cloudWatchAlarmEvent.AWSAccountID]
+}
+
+// accountSuppressed whether the alarm's account suppresses it
+func accountSuppressed(cloudWatchAlarmEvent CloudWatchAlarmEvent) bool {
+	for _, pattern := range accountOverride(cloudWatchAlarmEvent).Suppress {
+		if matched, _ := path.Match(pattern, cloudWatchAlarmEvent.AlarmName); matched {
+			return true
+		}
+	}
+	return false
+}
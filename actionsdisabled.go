@@ -0,0 +1,72 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// How notifications for alarms with their actions disabled are handled
+const (
+	actionsDisabledNotify   = "notify"
+	actionsDisabledLabel    = "label"
+	actionsDisabledSuppress = "suppress"
+)
+
+// checkActionsDisabled suppresses or labels the notification when the alarm's actions have been disabled in the
+// console, since whoever disabled them didn't want to hear about it either.  An alarm in another account is left as is.
+func checkActionsDisabled(ctx context.Context, n *notification) error {
+	if actionsDisabledPolicy == actionsDisabledNotify {
+		return nil
+	}
+
+	client, err := alarmCloudWatchService(ctx, n.cloudWatchAlarmEvent)
+	if err != nil || client == nil {
+		return err
+	}
+	output, err := client.DescribeAlarmsWithContext(ctx, &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: []*string{aws.String(n.cloudWatchAlarmEvent.AlarmName)},
+	})
+	if err != nil {
+		return err
+	}
+	if len(output.MetricAlarms) == 0 || aws.BoolValue(output.MetricAlarms[0].ActionsEnabled) {
+		return nil
+	}
+
+	if actionsDisabledPolicy == actionsDisabledSuppress {
+		n.suppressed = "actions are disabled on the alarm"
+		n.quiet = true
+		return nil
+	}
+
+	value := "Disabled"
+	if updated := output.MetricAlarms[0].AlarmConfigurationUpdatedTimestamp; updated != nil {
+		value = fmt.Sprintf("Disabled, alarm last updated %s ago", time.Since(*updated).Round(time.Minute))
+	}
+	n.slackAttachment.Title = ":mute: " + n.slackAttachment.Title
+	n.slackAttachment.AttachmentField = append(n.slackAttachment.AttachmentField, slack.AttachmentField{
+		Title: "Alarm Actions",
+		Value: value,
+		Short: true,
+	})
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// appConfigClient talks to the AppConfig Lambda extension, which is local so it isn't sent through the retries and
+// circuit breaker the destinations are
+var appConfigClient = http.Client{Timeout: 2 * time.Second}
+
+var (
+	appConfigDocument []byte
+	appConfigFetched  time.Time
+	appConfigMutex    sync.Mutex
+)
+
+// appConfigURL the extension's URL for the configuration profile named by APPCONFIG_APPLICATION,
+// APPCONFIG_ENVIRONMENT and APPCONFIG_PROFILE, empty when they aren't all set
+func appConfigURL() string {
+	application, environment, profile := getenv("APPCONFIG_APPLICATION"), getenv("APPCONFIG_ENVIRONMENT"), getenv("APPCONFIG_PROFILE")
+	if application == "" || environment == "" || profile == "" {
+		return ""
+	}
+	port := getenv("AWS_APPCONFIG_EXTENSION_HTTP_PORT")
+	if port == "" {
+		port = "2772"
+	}
+	return fmt.Sprintf("http://localhost:%s/applications/%s/environments/%s/configurations/%s", port, application, environment, profile)
+}
+
+// loadAppConfig fetches the configuration through the AppConfig extension, which does the polling and deployment
+// strategies itself, so all this has to do is ask it for the latest.  It returns whether the configuration changed.
+func loadAppConfig(ctx context.Context) (bool, error) {
+	url := appConfigURL()
+	if url == "" {
+		return false, nil
+	}
+
+	appConfigMutex.Lock()
+	defer appConfigMutex.Unlock()
+	appConfigFetched = time.Now()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := appConfigClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("extension returned %s", resp.Status)
+	}
+	document, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(document, appConfigDocument) {
+		return false, nil
+	}
+
+	values, err := parseConfigValues(document)
+	if err != nil {
+		return false, err
+	}
+	setConfigValues(configSourceAppConfig, values)
+	appConfigDocument = document
+	return true, nil
+}
+
+// refreshAppConfig asks the extension for the configuration once APPCONFIG_POLL_INTERVAL is up, configuring the
+// notifier again when it changed.  The last configuration is kept when it can't be fetched.
+func refreshAppConfig(ctx context.Context) {
+	appConfigMutex.Lock()
+	due := time.Since(appConfigFetched) >= appConfigPollInterval
+	appConfigMutex.Unlock()
+	if !due {
+		return
+	}
+
+	changed, err := loadAppConfig(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Loading AppConfig failed", "error", err)
+		return
+	}
+	if changed {
+		slog.InfoContext(ctx, "AppConfig configuration changed, reconfiguring")
+		reconfigure()
+	}
+}
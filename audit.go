@@ -0,0 +1,88 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// deliveryResponseLimit how much of a destination's response is kept on the delivery
+const deliveryResponseLimit = 1000
+
+// Delivery an outbound notification, recorded so it's possible to tell after the fact whether an alarm was notified
+type Delivery struct {
+	ID          string    `dynamodbav:"ID"`
+	Destination string    `dynamodbav:"Destination"`
+	AlarmARNs   []string  `dynamodbav:"AlarmArns,omitempty"`
+	PayloadHash string    `dynamodbav:"PayloadHash"`
+	Response    string    `dynamodbav:"Response,omitempty"`
+	Error       string    `dynamodbav:"Error,omitempty"`
+	SentAt      time.Time `dynamodbav:"SentAt"`
+	DurationMs  int64     `dynamodbav:"DurationMs"`
+}
+
+type alarmsContextKey struct{}
+
+// withAlarms attributes deliveries made with the context to the alarms
+func withAlarms(ctx context.Context, alarmARNs ...string) context.Context {
+	return context.WithValue(ctx, alarmsContextKey{}, alarmARNs)
+}
+
+// auditDelivery records the delivery of the payload to the destination, attributed to the alarms on the context
+func auditDelivery(ctx context.Context, destination string, payload interface{}, response interface{}, sendErr error, started time.Time) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.WarnContext(ctx, "Encoding the delivery payload failed", "destination", destination, "error", err)
+	}
+	hash := sha256.Sum256(body)
+	alarmARNs, _ := ctx.Value(alarmsContextKey{}).([]string)
+
+	delivery := Delivery{
+		ID:          newID(),
+		Destination: destination,
+		AlarmARNs:   alarmARNs,
+		PayloadHash: hex.EncodeToString(hash[:]),
+		SentAt:      started,
+		DurationMs:  int64(time.Since(started) / time.Millisecond),
+	}
+	if response != nil {
+		delivery.Response, _ = truncate(fmt.Sprint(response), deliveryResponseLimit)
+	}
+	// Channels would make too many metrics, so they're dimensioned by the kind of destination
+	kind := strings.SplitN(destination, ":", 2)[0]
+	emitMetric("DeliveryLatency", "Milliseconds", float64(delivery.DurationMs), map[string]string{"Destination": kind})
+	if sendErr != nil {
+		delivery.Error = sendErr.Error()
+		slog.ErrorContext(ctx, "Delivery failed", "destination", destination, "duration_ms", delivery.DurationMs, "error", sendErr)
+		emitMetric("DeliveryFailures", "Count", 1, map[string]string{"Destination": kind})
+	} else {
+		slog.InfoContext(ctx, "Delivered", "destination", destination, "duration_ms", delivery.DurationMs)
+		emitMetric("Deliveries", "Count", 1, map[string]string{"Destination": kind})
+	}
+
+	if stateStore == nil {
+		return
+	}
+	if err := stateStore.PutDelivery(ctx, delivery); err != nil {
+		slog.WarnContext(ctx, "Recording the delivery failed", "destination", destination, "error", err)
+	}
+}
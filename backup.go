@@ -0,0 +1,90 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// AWS Backup notifications are prose with "Label : value" pairs inline, e.g. "An AWS Backup job failed. Resource ARN :
+// arn:aws:ec2:... BackupJob ID : 1234"
+var backupLabels = regexp.MustCompile(`(Recovery point ARN|Resource ARN|Backup ?Job ID|Copy Job ID|Restore Job ID|Backup vault name|Backup vault)\s*:\s*(\S+?)\.?(\s|$)`)
+
+// backupFailedStates job states reported when the job didn't succeed
+var backupFailedStates = map[string]bool{
+	"FAILED":  true,
+	"ABORTED": true,
+	"EXPIRED": true,
+}
+
+// isBackupMessage detects AWS Backup notifications
+func isBackupMessage(sns events.SNSEntity) bool {
+	return strings.Contains(sns.Subject, "AWS Backup") || strings.HasPrefix(sns.Message, "An AWS Backup")
+}
+
+// backupMessage renders an AWS Backup job notification with its vault, resource and job.  Only failed jobs are posted
+// unless BACKUP_NOTIFY_ALL is set.
+func backupMessage(sns events.SNSEntity) (*OtherMessage, error) {
+	state := strings.ToUpper(messageAttribute(sns, "State"))
+	failed := backupFailedStates[state] || (state == "" && strings.Contains(sns.Message, "failed"))
+	if !failed && !backupNotifyAll {
+		slog.Info("Dropping AWS Backup message, the job didn't fail", "message_id", sns.MessageID, "state", state)
+		return nil, nil
+	}
+
+	fields := map[string]string{}
+	for _, match := range backupLabels.FindAllStringSubmatch(sns.Message, -1) {
+		fields[match[1]] = match[2]
+	}
+	attachmentFields := []slack.AttachmentField{}
+	for _, label := range []string{"Backup vault name", "Backup vault", "Resource ARN", "Recovery point ARN", "BackupJob ID", "Backup Job ID", "Copy Job ID", "Restore Job ID"} {
+		if value, ok := fields[label]; ok {
+			attachmentFields = append(attachmentFields, slack.AttachmentField{Title: label, Value: value, Short: false})
+		}
+	}
+
+	if state == "" {
+		state = "COMPLETED"
+		if failed {
+			state = "FAILED"
+		}
+	}
+	eventType := messageAttribute(sns, "EventType")
+	if eventType == "" {
+		eventType = "BACKUP_JOB"
+	}
+	color := "good"
+	if failed {
+		color = "danger"
+	}
+	text := sns.Message
+	if index := strings.Index(text, "."); index > 0 {
+		text = text[:index+1]
+	}
+	return &OtherMessage{Attachment: slack.Attachment{
+		Color:           color,
+		Title:           fmt.Sprintf(":floppy_disk: AWS Backup %s %s", eventType, state),
+		Text:            text,
+		Ts:              time.Now().Unix(),
+		AttachmentField: attachmentFields,
+	}}, nil
+}
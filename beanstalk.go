@@ -0,0 +1,75 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// Elastic Beanstalk describes health changes as "Environment health has transitioned from Ok to Severe." followed by
+// the cause
+var beanstalkHealthTransition = regexp.MustCompile(`health has transitioned from (\w+) to (\w+)\.?\s*(.*)`)
+
+// beanstalkHealthColors attachment color for each enhanced health status
+var beanstalkHealthColors = map[string]string{
+	"Ok":       "good",
+	"Info":     "good",
+	"Warning":  "warning",
+	"Degraded": "warning",
+	"Severe":   "danger",
+}
+
+// isBeanstalkMessage detects Elastic Beanstalk notifications
+func isBeanstalkMessage(sns events.SNSEntity) bool {
+	return strings.HasPrefix(sns.Subject, "AWS Elastic Beanstalk Notification")
+}
+
+// beanstalkMessage renders an Elastic Beanstalk notification with the environment, its health transition and the cause
+func beanstalkMessage(sns events.SNSEntity) (*OtherMessage, error) {
+	fields := messageFields(sns.Message, ":")
+
+	message := fields["Message"]
+	title := fmt.Sprintf(":seedling: %s: %s", fields["Environment"], message)
+	color := "#439FE0"
+	text := ""
+	attachmentFields := []slack.AttachmentField{
+		{Title: "Environment", Value: fields["Environment"], Short: true},
+		{Title: "Application", Value: fields["Application"], Short: true},
+	}
+	if match := beanstalkHealthTransition.FindStringSubmatch(message); match != nil {
+		title = fmt.Sprintf(":seedling: %s health %s → %s", fields["Environment"], match[1], match[2])
+		text = match[3]
+		if healthColor, ok := beanstalkHealthColors[match[2]]; ok {
+			color = healthColor
+		}
+	}
+	if environmentURL := fields["Environment URL"]; environmentURL != "" {
+		attachmentFields = append(attachmentFields, slack.AttachmentField{Title: "URL", Value: environmentURL, Short: false})
+	}
+
+	return &OtherMessage{Attachment: slack.Attachment{
+		Color:           color,
+		Title:           title,
+		Text:            text,
+		Ts:              time.Now().Unix(),
+		AttachmentField: attachmentFields,
+	}}, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// Keep the summary to a couple of lines, the full reason is in the details thread
+const summaryReasonLimit = 300
+
+// Block and action ID of the Details button on a summary
+const (
+	detailsBlockID  = "alarm_details"
+	detailsActionID = "details"
+)
+
+// detailsOnRequest whether the details of a summary wait for its Details button to be clicked.  The button needs
+// somewhere to send its callback, which the signing secret says has been set up, and the state store to keep the
+// details until then.
+func detailsOnRequest() bool {
+	return currentSlackSigningSecret() != "" && stateStore != nil
+}
+
+// summaryBlocks a compact Block Kit summary of the notification.  The full trigger details and enrichment are posted
+// as a reply in the message's thread, which Slack keeps collapsed until someone opens it, once its Details button is
+// clicked or straight away when there can't be a button.
+func summaryBlocks(n *notification) []map[string]interface{} {
+	reason, _ := truncate(n.cloudWatchAlarmEvent.NewStateReason, summaryReasonLimit)
+
+	links := fmt.Sprintf("<%s|Console>", consoleURL(n.cloudWatchAlarmEvent))
+	if n.runbook != "" {
+		links = fmt.Sprintf("%s · <%s|Runbook>", links, n.runbook)
+	}
+	footer := fmt.Sprintf("%s · %s · %s", n.cloudWatchAlarmEvent.AWSAccountID, n.cloudWatchAlarmEvent.Region, links)
+	if !detailsOnRequest() {
+		footer += " · Details in thread"
+	}
+
+	blocks := []map[string]interface{}{
+		{
+			"type": "section",
+			"text": map[string]string{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("%s *%s*\n%s", severityBadge(n.severity, n.cloudWatchAlarmEvent.NewStateValue), n.subject, reason),
+			},
+		},
+		{
+			"type": "context",
+			"elements": []map[string]string{
+				{
+					"type": "mrkdwn",
+					"text": footer,
+				},
+			},
+		},
+	}
+	if detailsOnRequest() {
+		blocks = append(blocks, map[string]interface{}{
+			"type":     "actions",
+			"block_id": detailsBlockID,
+			"elements": []map[string]interface{}{
+				{
+					"type":      "button",
+					"action_id": detailsActionID,
+					"text":      map[string]string{"type": "plain_text", "text": "Details"},
+					"value":     n.cloudWatchAlarmEvent.AlarmARN,
+				},
+			},
+		})
+	}
+	return blocks
+}
+
+// postDetails posts the details of the summary the Details button was clicked on into its thread, swapping the button
+// for a note saying they're there.  They're kept with the alarm's state, so only its latest message has them.
+func postDetails(ctx context.Context, interaction SlackInteraction, alarmARN string) error {
+	if stateStore == nil {
+		return fmt.Errorf("details require STATE_TABLE to be configured")
+	}
+	alarmState, err := stateStore.GetAlarmState(ctx, alarmARN)
+	if err != nil {
+		return err
+	}
+	if alarmState == nil || alarmState.MessageTs != interaction.Message.Ts || alarmState.Details == "" {
+		return fmt.Errorf("the details of %s are gone, it's been notified again since", alarmARN)
+	}
+	details := slack.Attachment{}
+	if err := json.Unmarshal([]byte(alarmState.Details), &details); err != nil {
+		return err
+	}
+
+	resp, err := postMessage(ctx, SlackMessage{
+		Channel:     interaction.Channel.ID,
+		ThreadTs:    interaction.Message.Ts,
+		Attachments: []slack.Attachment{details},
+	})
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Posted details", "alarm_arn", alarmARN, "ts", resp.Ts)
+
+	posted := map[string]interface{}{
+		"type": "context",
+		"elements": []map[string]string{
+			{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("Details in thread, asked for by <@%s>", interaction.User.ID),
+			},
+		},
+	}
+	return replaceBlock(ctx, interaction, detailsBlockID, posted)
+}
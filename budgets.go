@@ -0,0 +1,127 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// An amount as Budgets writes it, e.g. "$1,234.56" or "1234.56 USD", with any comparison in front of it
+var currencyAmount = regexp.MustCompile(`^[<>=\s]*([^\d\s.,-]*)\s*(-?[\d,]+(?:\.\d+)?)\s*([A-Z]{3})?$`)
+
+// Amount a sum of money keeping the currency it was written in
+type Amount struct {
+	Value  float64
+	Symbol string
+	Code   string
+}
+
+// ParseAmount parses an amount written with a currency symbol or code
+func ParseAmount(value string) (Amount, error) {
+	match := currencyAmount.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return Amount{}, fmt.Errorf("%q isn't an amount", value)
+	}
+	parsed, err := strconv.ParseFloat(strings.Replace(match[2], ",", "", -1), 64)
+	if err != nil {
+		return Amount{}, err
+	}
+	return Amount{Value: parsed, Symbol: match[1], Code: match[3]}, nil
+}
+
+// String the amount to two decimal places with thousands separated, in the currency it was written in
+func (amount Amount) String() string {
+	sign := ""
+	value := amount.Value
+	if value < 0 {
+		sign, value = "-", -value
+	}
+	digits := strconv.FormatFloat(value, 'f', 2, 64)
+	whole, cents := digits[:len(digits)-3], digits[len(digits)-3:]
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+
+	formatted := sign + amount.Symbol + whole + cents
+	if amount.Code != "" {
+		formatted += " " + amount.Code
+	}
+	return formatted
+}
+
+// isBudgetsMessage detects AWS Budgets notifications
+func isBudgetsMessage(sns events.SNSEntity) bool {
+	return strings.HasPrefix(sns.Subject, "AWS Budgets:") || strings.Contains(sns.Message, "AWS Budget Notification")
+}
+
+// budgetsMessage renders an AWS Budgets notification, which is plain text with the details as "Name: value" lines, for
+// the finance channel
+func budgetsMessage(sns events.SNSEntity) (*OtherMessage, error) {
+	details := messageFields(sns.Message, ":")
+	name := details["Budget Name"]
+	if name == "" {
+		return nil, fmt.Errorf("no Budget Name")
+	}
+
+	alertType := details["Alert Type"]
+	budgeted, budgetedErr := ParseAmount(details["Budgeted Amount"])
+	threshold, thresholdErr := ParseAmount(details["Alert Threshold"])
+	actual, actualErr := ParseAmount(details[alertType+" Amount"])
+
+	fields := []slack.AttachmentField{
+		{Title: "Budget", Value: name, Short: true},
+		{Title: "Type", Value: fmt.Sprintf("%s %s", details["Budget Type"], strings.ToLower(alertType)), Short: true},
+	}
+	text := ""
+	if budgetedErr == nil && actualErr == nil {
+		text = fmt.Sprintf("%s spend is %s of %s budgeted", strings.Title(strings.ToLower(alertType)), actual, budgeted)
+		if budgeted.Value != 0 {
+			text += fmt.Sprintf(" (%.0f%%)", actual.Value/budgeted.Value*100)
+		}
+		fields = append(fields,
+			slack.AttachmentField{Title: "Budgeted", Value: budgeted.String(), Short: true},
+			slack.AttachmentField{Title: alertType, Value: actual.String(), Short: true},
+		)
+	}
+	if budgetedErr == nil && thresholdErr == nil && budgeted.Value != 0 {
+		fields = append(fields, slack.AttachmentField{
+			Title: "Alert Threshold",
+			Value: fmt.Sprintf("%s (%.0f%% of budget)", threshold, threshold.Value/budgeted.Value*100),
+			Short: true,
+		})
+	}
+
+	color := "warning"
+	if actualErr == nil && budgetedErr == nil && actual.Value >= budgeted.Value {
+		color = "danger"
+	}
+	return &OtherMessage{
+		Channel: financeChannel,
+		Attachment: slack.Attachment{
+			Color:           color,
+			Title:           fmt.Sprintf(":moneybag: %s", sns.Subject),
+			Text:            text,
+			Ts:              time.Now().Unix(),
+			AttachmentField: fields,
+		},
+	}, nil
+}
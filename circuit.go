@@ -0,0 +1,87 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// circuitBreaker stops sending to a destination for a cooldown once that many requests to it in a row have failed, so a
+// dead webhook fails fast instead of using up the execution time left for every other record.  Destinations are told
+// apart by URL rather than host, the webhooks and failover webhook all being on hooks.slack.com.  Requests refused
+// while the circuit is open fail like any other send and are dead lettered.
+type circuitBreaker struct {
+	next      http.RoundTripper
+	threshold int
+	cooldown  time.Duration
+
+	mutex    sync.Mutex
+	failures map[string]int
+	openedAt map[string]time.Time
+}
+
+// newCircuitBreaker a circuit breaker in front of the transport, which is passed through when threshold isn't positive
+func newCircuitBreaker(next http.RoundTripper, threshold int, cooldown time.Duration) http.RoundTripper {
+	if threshold <= 0 {
+		return next
+	}
+	return &circuitBreaker{
+		next:      next,
+		threshold: threshold,
+		cooldown:  cooldown,
+		failures:  map[string]int{},
+		openedAt:  map[string]time.Time{},
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (breaker *circuitBreaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Webhook URLs are credentials, so only the host is logged
+	host := req.URL.Host
+	destination := req.URL.Scheme + "://" + host + req.URL.Path
+	breaker.mutex.Lock()
+	if opened, ok := breaker.openedAt[destination]; ok && time.Since(opened) < breaker.cooldown {
+		breaker.mutex.Unlock()
+		return nil, fmt.Errorf("circuit to %s is open after %d failures, retrying after %s", host, breaker.threshold, opened.Add(breaker.cooldown).Format(time.RFC3339))
+	}
+	breaker.mutex.Unlock()
+
+	resp, err := breaker.next.RoundTrip(req)
+
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	// Rate limiting means the destination is up, and is already backed off from by the retrying transport
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		return resp, err
+	}
+	if !retryable(resp, err) {
+		delete(breaker.failures, destination)
+		delete(breaker.openedAt, destination)
+		return resp, err
+	}
+	// Once the cooldown is up a single request is let through, failing it opens the circuit again straight away
+	breaker.failures[destination]++
+	if breaker.failures[destination] >= breaker.threshold {
+		if _, ok := breaker.openedAt[destination]; !ok {
+			slog.WarnContext(req.Context(), "Opening circuit", "destination", host, "cooldown", breaker.cooldown.String(), "failures", breaker.failures[destination])
+		}
+		breaker.openedAt[destination] = time.Now()
+	}
+	return resp, err
+}
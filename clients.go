@@ -0,0 +1,278 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// The clients are created the first time they're needed rather than in init, so a cold start only pays for the ones
+// the invocation uses and a bad AWS config is an error from the call needing it instead of a panic before the handler
+// runs.  A client that fails to be created is tried again next time.
+var (
+	clientsMutex     sync.Mutex
+	awsSession       *session.Session
+	cloudWatchClient *cloudwatch.CloudWatch
+	dynamoDBClient   *dynamodb.DynamoDB
+	kmsClient        *kms.KMS
+	s3Client         *s3.S3
+	secretsClient    *secretsmanager.SecretsManager
+	ssmClient        *ssm.SSM
+	sqsClient        *sqs.SQS
+	taggingClient    *resourcegroupstaggingapi.ResourceGroupsTaggingAPI
+	slackClient      *webhookClient
+
+	// regionalCloudWatchClients and regionalTaggingClients the clients for alarms in other regions, by region
+	regionalCloudWatchClients = map[string]*cloudwatch.CloudWatch{}
+	regionalTaggingClients    = map[string]*resourcegroupstaggingapi.ResourceGroupsTaggingAPI{}
+)
+
+// sharedSession the session every AWS client is created from.  clientsMutex has to be held.
+func sharedSession() (*session.Session, error) {
+	if awsSession == nil {
+		sess, err := session.NewSession()
+		if err != nil {
+			return nil, fmt.Errorf("creating the AWS session, check the function's AWS_* env vars: %s", err)
+		}
+		awsSession = sess
+	}
+	return awsSession, nil
+}
+
+// cloudWatchService the CloudWatch client
+func cloudWatchService() (*cloudwatch.CloudWatch, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	if cloudWatchClient == nil {
+		sess, err := sharedSession()
+		if err != nil {
+			return nil, err
+		}
+		cloudWatchClient = cloudwatch.New(sess)
+		traceClients(cloudWatchClient.Client)
+	}
+	return cloudWatchClient, nil
+}
+
+// dynamoDBService the DynamoDB client
+func dynamoDBService() (*dynamodb.DynamoDB, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	if dynamoDBClient == nil {
+		sess, err := sharedSession()
+		if err != nil {
+			return nil, err
+		}
+		dynamoDBClient = dynamodb.New(sess)
+		traceClients(dynamoDBClient.Client)
+	}
+	return dynamoDBClient, nil
+}
+
+// s3Service the S3 client
+func s3Service() (*s3.S3, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	if s3Client == nil {
+		sess, err := sharedSession()
+		if err != nil {
+			return nil, err
+		}
+		s3Client = s3.New(sess)
+		traceClients(s3Client.Client)
+	}
+	return s3Client, nil
+}
+
+// secretsManagerService the Secrets Manager client
+func secretsManagerService() (*secretsmanager.SecretsManager, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	if secretsClient == nil {
+		sess, err := sharedSession()
+		if err != nil {
+			return nil, err
+		}
+		secretsClient = secretsmanager.New(sess)
+		traceClients(secretsClient.Client)
+	}
+	return secretsClient, nil
+}
+
+// kmsService the KMS client
+func kmsService() (*kms.KMS, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	if kmsClient == nil {
+		sess, err := sharedSession()
+		if err != nil {
+			return nil, err
+		}
+		kmsClient = kms.New(sess)
+		traceClients(kmsClient.Client)
+	}
+	return kmsClient, nil
+}
+
+// ssmService the SSM client
+func ssmService() (*ssm.SSM, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	if ssmClient == nil {
+		sess, err := sharedSession()
+		if err != nil {
+			return nil, err
+		}
+		ssmClient = ssm.New(sess)
+		traceClients(ssmClient.Client)
+	}
+	return ssmClient, nil
+}
+
+// sqsService the SQS client
+func sqsService() (*sqs.SQS, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	if sqsClient == nil {
+		sess, err := sharedSession()
+		if err != nil {
+			return nil, err
+		}
+		sqsClient = sqs.New(sess)
+		traceClients(sqsClient.Client)
+	}
+	return sqsClient, nil
+}
+
+// taggingService the Resource Groups Tagging API client
+func taggingService() (*resourcegroupstaggingapi.ResourceGroupsTaggingAPI, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	if taggingClient == nil {
+		sess, err := sharedSession()
+		if err != nil {
+			return nil, err
+		}
+		taggingClient = resourcegroupstaggingapi.New(sess)
+		traceClients(taggingClient.Client)
+	}
+	return taggingClient, nil
+}
+
+// functionSource the partition and account the function runs in, empty outside an invocation
+func functionSource(ctx context.Context) EventSource {
+	lc, ok := lambdacontext.FromContext(ctx)
+	if !ok {
+		return EventSource{}
+	}
+	fields := strings.Split(lc.InvokedFunctionArn, ":")
+	if len(fields) < 5 {
+		return EventSource{}
+	}
+	return EventSource{Partition: fields[1], AccountID: fields[4], Region: fields[3]}
+}
+
+// foreignAlarm whether the alarm is in another account or partition than the function, where its role can't look up
+// the alarm's metrics, tags or configuration
+func foreignAlarm(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent) bool {
+	function, source := functionSource(ctx), cloudWatchAlarmEvent.Source()
+	if function.AccountID == "" || source.AccountID == "" {
+		return false
+	}
+	return function.AccountID != source.AccountID || function.Partition != source.Partition
+}
+
+// alarmCloudWatchService the CloudWatch client for the region the alarm is in, nil for a foreign alarm, which isn't
+// enriched
+func alarmCloudWatchService(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent) (*cloudwatch.CloudWatch, error) {
+	if foreignAlarm(ctx, cloudWatchAlarmEvent) {
+		return nil, nil
+	}
+	region := cloudWatchAlarmEvent.Source().Region
+	client, err := cloudWatchService()
+	if err != nil || region == "" || region == aws.StringValue(client.Config.Region) {
+		return client, err
+	}
+
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	if regional, ok := regionalCloudWatchClients[region]; ok {
+		return regional, nil
+	}
+	regional := cloudwatch.New(awsSession, aws.NewConfig().WithRegion(region))
+	traceClients(regional.Client)
+	regionalCloudWatchClients[region] = regional
+	return regional, nil
+}
+
+// alarmTaggingService the Resource Groups Tagging API client for the region the alarm is in, nil for a foreign alarm,
+// which isn't enriched
+func alarmTaggingService(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent) (*resourcegroupstaggingapi.ResourceGroupsTaggingAPI, error) {
+	if foreignAlarm(ctx, cloudWatchAlarmEvent) {
+		return nil, nil
+	}
+	region := cloudWatchAlarmEvent.Source().Region
+	client, err := taggingService()
+	if err != nil || region == "" || region == aws.StringValue(client.Config.Region) {
+		return client, err
+	}
+
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	if regional, ok := regionalTaggingClients[region]; ok {
+		return regional, nil
+	}
+	regional := resourcegroupstaggingapi.New(awsSession, aws.NewConfig().WithRegion(region))
+	traceClients(regional.Client)
+	regionalTaggingClients[region] = regional
+	return regional, nil
+}
+
+// slackWebhookClient the client for SLACK_WEBHOOK, which has to be set unless SLACK_BOT_TOKEN is
+func slackWebhookClient() (*webhookClient, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	if slackClient == nil {
+		client, err := newWebhookClient("SLACK_WEBHOOK", slackWebhook)
+		if err != nil {
+			return nil, err
+		}
+		slackClient = client
+	}
+	return slackClient, nil
+}
+
+// newWebhookClient a client for the Slack incoming webhook set by the env var, checking it's there and a URL first
+func newWebhookClient(name string, webhook string) (*webhookClient, error) {
+	if err := validWebhook(name, webhook); err != nil {
+		return nil, err
+	}
+	return &webhookClient{url: webhook}, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// isCloudFormationMessage detects CloudFormation stack event notifications
+func isCloudFormationMessage(sns events.SNSEntity) bool {
+	return strings.HasPrefix(sns.Message, "StackId=")
+}
+
+// cloudFormationMessage renders the stack level transitions worth knowing about from a CloudFormation stack
+// notification topic: stacks finishing, failing and rolling back.  Every other event, including every resource event,
+// is recognized and dropped so the stream of events doesn't flood the channel.
+func cloudFormationMessage(sns events.SNSEntity) (*OtherMessage, error) {
+	// Every field is on its own Name='value' line
+	fields := messageFields(sns.Message, "=")
+	for name, value := range fields {
+		fields[name] = strings.Trim(value, "'")
+	}
+
+	if fields["ResourceType"] != "AWS::CloudFormation::Stack" {
+		return nil, nil
+	}
+	status := fields["ResourceStatus"]
+	if strings.HasSuffix(status, "_IN_PROGRESS") && !strings.HasSuffix(status, "ROLLBACK_IN_PROGRESS") {
+		return nil, nil
+	}
+
+	color := "good"
+	if strings.HasSuffix(status, "_FAILED") || strings.Contains(status, "ROLLBACK") {
+		color = "danger"
+	}
+
+	stackID := fields["StackId"]
+	region := alarmRegion(stackID)
+	link := fmt.Sprintf("https://%s/cloudformation/home?region=%s#/stacks/stackinfo?stackId=%s", consoleHost(regionPartition(region)), region, url.QueryEscape(stackID))
+	text := fmt.Sprintf("<%s|Stack events>", link)
+	if reason := fields["ResourceStatusReason"]; reason != "" {
+		text = fmt.Sprintf("%s\n%s", reason, text)
+	}
+
+	return &OtherMessage{Attachment: slack.Attachment{
+		Color: color,
+		Title: fmt.Sprintf(":building_construction: %s %s", fields["StackName"], status),
+		Text:  text,
+		Ts:    time.Now().Unix(),
+		AttachmentField: []slack.AttachmentField{
+			{Title: "AccountID", Value: fields["Namespace"], Short: true},
+			{Title: "Region", Value: region, Short: true},
+		},
+	}}, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// CodeStarNotification a CodeStar Notifications payload, which is the EventBridge event with the detail type spelled
+// detailType
+type CodeStarNotification struct {
+	Account    string `json:"account"`
+	DetailType string `json:"detailType"`
+	Region     string `json:"region"`
+	Source     string `json:"source"`
+	Detail     struct {
+		Pipeline    string `json:"pipeline"`
+		ExecutionID string `json:"execution-id"`
+		Stage       string `json:"stage"`
+		Action      string `json:"action"`
+		State       string `json:"state"`
+		ProjectName string `json:"project-name"`
+		BuildID     string `json:"build-id"`
+		BuildStatus string `json:"build-status"`
+		Phase       string `json:"current-phase"`
+	} `json:"detail"`
+}
+
+// deployStateColor attachment color for a pipeline or build state
+func deployStateColor(state string) string {
+	switch state {
+	case "SUCCEEDED":
+		return "good"
+	case "FAILED", "FAULT", "TIMED_OUT":
+		return "danger"
+	case "STARTED", "RESUMED", "IN_PROGRESS":
+		return "#439FE0"
+	default:
+		return "warning"
+	}
+}
+
+// codePipelineMessage renders CodeStar Notifications for pipeline executions, stages and actions and for builds, with a
+// link to the execution or build in the console
+func codePipelineMessage(sns events.SNSEntity) (*OtherMessage, error) {
+	notification := CodeStarNotification{}
+	if err := json.Unmarshal([]byte(sns.Message), &notification); err != nil {
+		return nil, err
+	}
+	detail := notification.Detail
+
+	var title, link, state string
+	fields := []slack.AttachmentField{}
+	switch notification.Source {
+	case "aws.codepipeline":
+		state = detail.State
+		subject := "pipeline " + detail.Pipeline
+		if detail.Action != "" {
+			subject = fmt.Sprintf("action %s in stage %s of %s", detail.Action, detail.Stage, subject)
+		} else if detail.Stage != "" {
+			subject = fmt.Sprintf("stage %s of %s", detail.Stage, subject)
+		}
+		title = fmt.Sprintf(":rocket: CodePipeline %s %s", subject, state)
+		link = fmt.Sprintf("https://%s/codesuite/codepipeline/pipelines/%s/executions/%s/timeline?region=%s",
+			consoleHost(regionPartition(notification.Region)), url.PathEscape(detail.Pipeline), detail.ExecutionID, notification.Region)
+		fields = append(fields,
+			slack.AttachmentField{Title: "Pipeline", Value: detail.Pipeline, Short: true},
+			slack.AttachmentField{Title: "Execution", Value: detail.ExecutionID, Short: true},
+		)
+	case "aws.codebuild":
+		state = detail.BuildStatus
+		if state == "" {
+			state = detail.Phase
+		}
+		title = fmt.Sprintf(":hammer_and_wrench: CodeBuild %s %s", detail.ProjectName, state)
+		build := detail.BuildID[strings.LastIndex(detail.BuildID, "/")+1:]
+		link = fmt.Sprintf("https://%s/codesuite/codebuild/projects/%s/build/%s/?region=%s",
+			consoleHost(regionPartition(notification.Region)), url.PathEscape(detail.ProjectName), url.PathEscape(build), notification.Region)
+		fields = append(fields,
+			slack.AttachmentField{Title: "Project", Value: detail.ProjectName, Short: true},
+			slack.AttachmentField{Title: "Build", Value: build, Short: true},
+		)
+	default:
+		return nil, fmt.Errorf("unknown source %s", notification.Source)
+	}
+
+	fields = append(fields,
+		slack.AttachmentField{Title: "AccountID", Value: notification.Account, Short: true},
+		slack.AttachmentField{Title: "Region", Value: notification.Region, Short: true},
+	)
+	return &OtherMessage{Attachment: slack.Attachment{
+		Color:           deployStateColor(state),
+		Title:           title,
+		Text:            fmt.Sprintf("%s\n<%s|Console>", notification.DetailType, link),
+		Ts:              time.Now().Unix(),
+		AttachmentField: fields,
+	}}, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// handleCommand runs the slash command, responding privately to the user when the command fails
+func handleCommand(ctx context.Context, command string, form url.Values) SlackCommandResponse {
+	switch command {
+	case "/silence":
+		return silenceCommand(ctx, form)
+	case "/alarms":
+		return alarmsCommand(ctx, form)
+	default:
+		return SlackCommandResponse{ResponseType: "ephemeral", Text: fmt.Sprintf("Unknown command %s", command)}
+	}
+}
+
+// silenceCommand handles `/silence <alarm-pattern> <duration>` by recording a suppression for alarms matching the pattern
+func silenceCommand(ctx context.Context, form url.Values) SlackCommandResponse {
+	usage := SlackCommandResponse{ResponseType: "ephemeral", Text: "Usage: /silence <alarm-pattern> <duration>, e.g. /silence prod-api-* 2h"}
+
+	args := strings.Fields(form.Get("text"))
+	if len(args) != 2 {
+		return usage
+	}
+	if _, err := path.Match(args[0], ""); err != nil {
+		return usage
+	}
+	duration, err := time.ParseDuration(args[1])
+	if err != nil || duration <= 0 {
+		return usage
+	}
+
+	if stateStore == nil {
+		return SlackCommandResponse{ResponseType: "ephemeral", Text: "Silencing requires STATE_TABLE to be configured"}
+	}
+
+	now := time.Now()
+	suppression := Suppression{
+		ID:           newID(),
+		AlarmPattern: args[0],
+		Start:        now,
+		End:          now.Add(duration),
+		Reason:       fmt.Sprintf("silenced by @%s", form.Get("user_name")),
+	}
+	if err := stateStore.PutSuppression(ctx, suppression); err != nil {
+		slog.ErrorContext(ctx, "Silencing failed", "alarm_pattern", args[0], "error", err)
+		return SlackCommandResponse{ResponseType: "ephemeral", Text: fmt.Sprintf("Failed to silence %s: %s", args[0], err)}
+	}
+
+	return SlackCommandResponse{
+		ResponseType: "in_channel",
+		Text:         fmt.Sprintf(":mute: <@%s> silenced `%s` for %s (until %s)", form.Get("user_id"), args[0], duration, suppression.End.UTC().Format(time.RFC1123)),
+	}
+}
+
+// alarmsCommand handles `/alarms <subcommand>`, listing the alarms in ALARM without one
+func alarmsCommand(ctx context.Context, form url.Values) SlackCommandResponse {
+	args := strings.Fields(form.Get("text"))
+	if len(args) == 0 {
+		return listAlarmsCommand(ctx)
+	}
+	if args[0] == "mute" {
+		return muteCommand(ctx, form, args[1:])
+	}
+	return SlackCommandResponse{ResponseType: "ephemeral", Text: "Usage: /alarms or /alarms mute <duration>"}
+}
+
+// listAlarmsCommand handles `/alarms` by listing every alarm in ALARM with its severity and how long it's been firing,
+// longest first.  It's only shown to whoever asked.
+func listAlarmsCommand(ctx context.Context) SlackCommandResponse {
+	firing, err := firingAlarms(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Listing the alarms failed", "error", err)
+		return SlackCommandResponse{ResponseType: "ephemeral", Text: fmt.Sprintf("Failed to list the alarms: %s", err)}
+	}
+	if len(firing) == 0 {
+		return SlackCommandResponse{ResponseType: "ephemeral", Text: ":white_check_mark: No alarms are in ALARM"}
+	}
+
+	lines := []string{fmt.Sprintf(":fire: %d alarms in ALARM", len(firing))}
+	for _, alarmState := range firing {
+		event := alarmState.Event
+		event.AlarmARN, event.AlarmName = alarmState.AlarmARN, alarmState.AlarmName
+		line := fmt.Sprintf("%s <%s|%s> for %s", severityBadge(alarmSeverity(event), "ALARM"), consoleURL(event),
+			alarmState.AlarmName, time.Since(alarmState.TransitionTime).Round(time.Minute))
+		if alarmState.AcknowledgedBy != "" {
+			line += fmt.Sprintf(", acknowledged by <@%s>", alarmState.AcknowledgedBy)
+		}
+		lines = append(lines, line)
+	}
+	text, _ := truncate(strings.Join(lines, "\n"), slackTextLimit)
+	return SlackCommandResponse{ResponseType: "ephemeral", Text: text}
+}
+
+// muteCommand handles `/alarms mute <duration>` by recording a suppression for every notification to the channel
+func muteCommand(ctx context.Context, form url.Values, args []string) SlackCommandResponse {
+	usage := SlackCommandResponse{ResponseType: "ephemeral", Text: "Usage: /alarms mute <duration>, e.g. /alarms mute 30m"}
+	if len(args) != 1 {
+		return usage
+	}
+	duration, err := time.ParseDuration(args[0])
+	if err != nil || duration <= 0 {
+		return usage
+	}
+
+	if stateStore == nil {
+		return SlackCommandResponse{ResponseType: "ephemeral", Text: "Muting requires STATE_TABLE to be configured"}
+	}
+
+	now := time.Now()
+	suppression := Suppression{
+		ID:          newID(),
+		Channel:     form.Get("channel_id"),
+		ChannelName: form.Get("channel_name"),
+		Start:       now,
+		End:         now.Add(duration),
+		Reason:      fmt.Sprintf("channel muted by @%s", form.Get("user_name")),
+	}
+	if err := stateStore.PutSuppression(ctx, suppression); err != nil {
+		slog.ErrorContext(ctx, "Muting the channel failed", "channel", form.Get("channel_name"), "error", err)
+		return SlackCommandResponse{ResponseType: "ephemeral", Text: fmt.Sprintf("Failed to mute this channel: %s", err)}
+	}
+
+	return SlackCommandResponse{
+		ResponseType: "in_channel",
+		Text:         fmt.Sprintf(":mute: <@%s> muted alarm notifications to this channel for %s (until %s)", form.Get("user_id"), duration, suppression.End.UTC().Format(time.RFC1123)),
+	}
+}
+
+// newID a random identifier for records the notifier creates
+func newID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,138 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	configFileETag     string
+	configFileDocument []byte
+	configFileChecked  time.Time
+	configFileMutex    sync.Mutex
+)
+
+// loadConfigFile reads the YAML config file, a mapping of settings named like their env vars with routes, templates and
+// the like written out as YAML.  CONFIG_FILE is a path to one packaged with the function or in a layer, otherwise it's
+// fetched from CONFIG_KEY in CONFIG_BUCKET, only downloaded again once its ETag changes.  It returns whether the file
+// changed.
+func loadConfigFile(ctx context.Context) (bool, error) {
+	if path := getenv("CONFIG_FILE"); path != "" {
+		return loadLocalConfigFile(path)
+	}
+	bucket := getenv("CONFIG_BUCKET")
+	if bucket == "" {
+		return false, nil
+	}
+	key := envString("CONFIG_KEY")
+
+	configFileMutex.Lock()
+	defer configFileMutex.Unlock()
+	configFileChecked = time.Now()
+
+	client, err := s3Service()
+	if err != nil {
+		return false, err
+	}
+	input := &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	if configFileETag != "" {
+		input.IfNoneMatch = aws.String(configFileETag)
+	}
+	output, err := client.GetObjectWithContext(ctx, input)
+	if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == 304 {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer output.Body.Close()
+
+	document, err := io.ReadAll(output.Body)
+	if err != nil {
+		return false, err
+	}
+	if err := setConfigFile(document); err != nil {
+		return false, err
+	}
+	configFileETag = aws.StringValue(output.ETag)
+	return true, nil
+}
+
+// loadLocalConfigFile reads the config file at the path, returning whether it changed since it was last read
+func loadLocalConfigFile(path string) (bool, error) {
+	configFileMutex.Lock()
+	defer configFileMutex.Unlock()
+	configFileChecked = time.Now()
+
+	document, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(document, configFileDocument) {
+		return false, nil
+	}
+	if err := setConfigFile(document); err != nil {
+		return false, err
+	}
+	configFileDocument = document
+	return true, nil
+}
+
+// setConfigFile puts the settings in the YAML config file in place
+func setConfigFile(document []byte) error {
+	converted, err := yaml.YAMLToJSON(document)
+	if err != nil {
+		return err
+	}
+	values, err := parseConfigValues(converted)
+	if err != nil {
+		return err
+	}
+	setConfigValues(configSourceFile, values)
+	return nil
+}
+
+// refreshConfigFile checks the config file's ETag once CONFIG_REFRESH is up, configuring the notifier again when it
+// changed.  The last config is kept when it can't be fetched.
+func refreshConfigFile(ctx context.Context) {
+	configFileMutex.Lock()
+	due := time.Since(configFileChecked) >= configRefresh
+	configFileMutex.Unlock()
+	if !due {
+		return
+	}
+
+	changed, err := loadConfigFile(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Loading the config file failed", "error", err)
+		return
+	}
+	if changed {
+		slog.InfoContext(ctx, "Config file changed, reconfiguring")
+		reconfigure()
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+var (
+	configParameters        map[string]string
+	configParametersChecked time.Time
+	configParametersMutex   sync.Mutex
+)
+
+// loadConfigParameters fetches the SSM parameters under CONFIG_PARAMETER_PATH, each a setting named by the last part
+// of its name, e.g. /notifier/prod/SLACK_MONITOR_CHANNEL.  SecureString parameters are decrypted.  It returns whether
+// any of them changed.
+func loadConfigParameters(ctx context.Context) (bool, error) {
+	parameterPath := getenv("CONFIG_PARAMETER_PATH")
+	if parameterPath == "" {
+		return false, nil
+	}
+
+	configParametersMutex.Lock()
+	defer configParametersMutex.Unlock()
+	configParametersChecked = time.Now()
+
+	client, err := ssmService()
+	if err != nil {
+		return false, err
+	}
+	values := map[string]string{}
+	input := &ssm.GetParametersByPathInput{
+		Path:           aws.String(parameterPath),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	}
+	err = client.GetParametersByPathPagesWithContext(ctx, input, func(output *ssm.GetParametersByPathOutput, last bool) bool {
+		for _, parameter := range output.Parameters {
+			values[path.Base(aws.StringValue(parameter.Name))] = aws.StringValue(parameter.Value)
+		}
+		return true
+	})
+	if err != nil {
+		return false, err
+	}
+	if reflect.DeepEqual(values, configParameters) {
+		return false, nil
+	}
+	setConfigValues(configSourceSSM, values)
+	configParameters = values
+	return true, nil
+}
+
+// refreshConfigParameters fetches the parameters again once CONFIG_REFRESH is up, configuring the notifier again when
+// they changed.  The last parameters are kept when they can't be fetched.
+func refreshConfigParameters(ctx context.Context) {
+	configParametersMutex.Lock()
+	due := time.Since(configParametersChecked) >= configRefresh
+	configParametersMutex.Unlock()
+	if !due {
+		return
+	}
+
+	changed, err := loadConfigParameters(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Loading CONFIG_PARAMETER_PATH failed", "error", err)
+		return
+	}
+	if changed {
+		slog.InfoContext(ctx, "Config parameters changed, reconfiguring")
+		reconfigure()
+	}
+}
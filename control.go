@@ -0,0 +1,80 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// controlReload the control message asking for the configuration and secrets to be fetched again
+const controlReload = "reload"
+
+// controlMessage whether the SNS message is a control message rather than something to notify about, a JSON object
+// like {"control": "reload"}.  With CONTROL_TOPIC_ARN set only messages from that topic are, otherwise they can come
+// through any of the topics the notifier is subscribed to.
+func controlMessage(sns events.SNSEntity) (string, bool) {
+	if topic := getenv("CONTROL_TOPIC_ARN"); topic != "" && unwrapEnvelope(sns).TopicArn != topic {
+		return "", false
+	}
+	message := struct {
+		Control string `json:"control"`
+	}{}
+	if err := json.Unmarshal([]byte(unwrapEnvelope(sns).Message), &message); err != nil || message.Control == "" {
+		return "", false
+	}
+	return message.Control, true
+}
+
+// reloadConfig fetches the config file, config parameters, AppConfig, secrets and kill switch straight away rather than
+// waiting for them to be due.  Only the instance the control message is delivered to reloads, the other warm ones
+// still pick the changes up when their own refreshes come round.
+func reloadConfig(ctx context.Context) {
+	slog.InfoContext(ctx, "Reloading the configuration and secrets")
+	ctx = bypassSecretsExtension(ctx)
+	configFileMutex.Lock()
+	configFileChecked = time.Time{}
+	configFileMutex.Unlock()
+	configParametersMutex.Lock()
+	configParametersChecked = time.Time{}
+	configParametersMutex.Unlock()
+	appConfigMutex.Lock()
+	appConfigFetched = time.Time{}
+	appConfigMutex.Unlock()
+	secretsMutex.Lock()
+	secretsLoaded = time.Time{}
+	secretsMutex.Unlock()
+	credentialsCacheMutex.Lock()
+	for reference, cached := range credentialsCache {
+		cached.reloaded = true
+		credentialsCache[reference] = cached
+	}
+	credentialsCacheMutex.Unlock()
+	killSwitchMutex.Lock()
+	killSwitchFetched = time.Time{}
+	killSwitchMutex.Unlock()
+	parameterSuppressionsMutex.Lock()
+	parameterSuppressionsFetched = time.Time{}
+	parameterSuppressionsMutex.Unlock()
+
+	refreshConfigFile(ctx)
+	refreshConfigParameters(ctx)
+	refreshAppConfig(ctx)
+	loadSecrets(ctx)
+}
@@ -0,0 +1,263 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// AlarmGroup an incident made up of the alarms sharing a correlation tag value, along with the message they were
+// grouped under.  It opens when the first alarm fires, takes in every related alarm that fires while it's open or
+// within the correlation window, and closes once all of them are back to OK.
+type AlarmGroup struct {
+	Key     string `dynamodbav:"Key"`
+	Channel string `dynamodbav:"Channel,omitempty"`
+	Ts      string `dynamodbav:"Ts,omitempty"`
+	// Alarms the name of every alarm that has been part of the incident
+	Alarms []string `dynamodbav:"Alarms"`
+	// Firing the ARN of every alarm in the incident still in ALARM
+	Firing    []string  `dynamodbav:"Firing,omitempty"`
+	Opened    time.Time `dynamodbav:"Opened"`
+	LastAlarm time.Time `dynamodbav:"LastAlarm"`
+	Closed    time.Time `dynamodbav:"Closed"`
+}
+
+// Open whether the incident still has alarms in ALARM
+func (group AlarmGroup) Open() bool {
+	return len(group.Firing) != 0
+}
+
+// joinable whether alarms firing now become part of the incident rather than starting a new one
+func (group AlarmGroup) joinable() bool {
+	return group.Open() || (group.Closed.IsZero() && time.Since(group.LastAlarm) < correlationWindow)
+}
+
+// appendUnique appends the value unless the list already has it
+func appendUnique(list []string, value string) []string {
+	for _, existing := range list {
+		if existing == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// correlationKey the value of the alarm's correlation tag, empty if it doesn't have one
+func correlationKey(ctx context.Context, alarmARN string) string {
+	tags, err := alarmTags(ctx, alarmARN)
+	if err != nil {
+		slog.WarnContext(ctx, "Looking up the correlation tag failed", "alarm_arn", alarmARN, "error", err)
+	}
+	return tags[correlationTag]
+}
+
+// correlate groups ALARM notifications by the value of their correlation tag.  Alarms joining an open incident are
+// posted in its thread, several alarms for the same group in one batch are
+// posted as a single message listing all of them, and everything else is returned to be sent as usual.
+func correlate(ctx context.Context, active []*notification) []*notification {
+	remaining := []*notification{}
+	keys := []string{}
+	groups := map[string][]*notification{}
+	for _, n := range active {
+		if n.cloudWatchAlarmEvent.NewStateValue != "ALARM" {
+			remaining = append(remaining, n)
+			continue
+		}
+
+		key := correlationKey(ctx, n.cloudWatchAlarmEvent.AlarmARN)
+		if key == "" {
+			remaining = append(remaining, n)
+			continue
+		}
+
+		n.correlationKey = key
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], n)
+	}
+
+	for _, key := range keys {
+		grouped := groups[key]
+		var group *AlarmGroup
+		if stateStore != nil {
+			var err error
+			if group, err = stateStore.GetAlarmGroup(ctx, key); err != nil {
+				slog.WarnContext(ctx, "Loading the alarm group failed", "group", key, "error", err)
+			}
+		}
+
+		switch {
+		case group != nil && group.Ts != "" && currentSlackBotToken() != "" && group.joinable():
+			for _, n := range grouped {
+				resp, err := postMessage(withAlarms(ctx, n.cloudWatchAlarmEvent.AlarmARN), SlackMessage{
+					Channel:     group.Channel,
+					ThreadTs:    group.Ts,
+					Attachments: []slack.Attachment{n.slackAttachment},
+					Metadata:    alarmMetadata(n.cloudWatchAlarmEvent, n.severity),
+				})
+				if err != nil {
+					slog.ErrorContext(ctx, "Posting to the incident thread failed", "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "group", key, "error", err)
+					n.failed = err
+					continue
+				}
+				n.channel, n.ts = resp.Channel, resp.Ts
+				group.Alarms = appendUnique(group.Alarms, n.cloudWatchAlarmEvent.AlarmName)
+				group.Firing = appendUnique(group.Firing, n.cloudWatchAlarmEvent.AlarmARN)
+			}
+		case len(grouped) > 1:
+			group = &AlarmGroup{Key: key, Opened: time.Now()}
+			lines := []string{}
+			for _, n := range grouped {
+				reason, _ := truncate(n.cloudWatchAlarmEvent.NewStateReason, summaryReasonLimit)
+				lines = append(lines, fmt.Sprintf("• *%s* %s", n.cloudWatchAlarmEvent.AlarmName, reason))
+				group.Alarms = appendUnique(group.Alarms, n.cloudWatchAlarmEvent.AlarmName)
+				group.Firing = appendUnique(group.Firing, n.cloudWatchAlarmEvent.AlarmARN)
+			}
+			incident := slack.Attachment{
+				Color: severityColor(grouped[0].severity, "ALARM"),
+				Title: fmt.Sprintf(":link: %d alarms firing for %s %s", len(grouped), correlationTag, key),
+				Text:  strings.Join(lines, "\n"),
+				Ts:    time.Now().Unix(),
+			}
+
+			ctx := withAlarms(ctx, group.Firing...)
+			if currentSlackBotToken() != "" {
+				resp, err := postMessage(ctx, SlackMessage{Channel: slackMonitorChannel, Attachments: []slack.Attachment{incident}})
+				if err != nil {
+					slog.ErrorContext(ctx, "Opening the incident failed", "group", key, "error", err)
+					remaining = append(remaining, grouped...)
+					continue
+				}
+				group.Channel, group.Ts = resp.Channel, resp.Ts
+				for _, n := range grouped {
+					n.channel, n.ts = resp.Channel, resp.Ts
+				}
+			} else {
+				if err := postAttachments(ctx, slackMonitorChannel, []slack.Attachment{incident}); err != nil {
+					for _, n := range grouped {
+						n.failed = err
+					}
+				}
+			}
+		default:
+			// The first alarm of a group goes out on its own, dispatch records the group once it knows where it was posted
+			remaining = append(remaining, grouped...)
+			continue
+		}
+
+		group.LastAlarm = time.Now()
+		if stateStore != nil {
+			if err := stateStore.PutAlarmGroup(ctx, *group); err != nil {
+				slog.WarnContext(ctx, "Saving the alarm group failed", "group", key, "error", err)
+			}
+		}
+	}
+	return remaining
+}
+
+// openGroup records the group started by the notification that was sent on its own
+func openGroup(ctx context.Context, n *notification) {
+	err := stateStore.PutAlarmGroup(ctx, AlarmGroup{
+		Key:       n.correlationKey,
+		Channel:   n.channel,
+		Ts:        n.ts,
+		Alarms:    []string{n.cloudWatchAlarmEvent.AlarmName},
+		Firing:    []string{n.cloudWatchAlarmEvent.AlarmARN},
+		Opened:    time.Now(),
+		LastAlarm: time.Now(),
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Saving the alarm group failed", "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "error", err)
+	}
+}
+
+// closeIncidents takes alarms back in OK out of their incident, closing the incident with a resolution summary once
+// none of its alarms are firing.  Suppressed notifications count too, an alarm is back to OK whether or not anyone was
+// told.
+func closeIncidents(ctx context.Context, notifications []*notification) {
+	for _, n := range notifications {
+		if n.cloudWatchAlarmEvent.NewStateValue != "OK" {
+			continue
+		}
+		key := correlationKey(ctx, n.cloudWatchAlarmEvent.AlarmARN)
+		if key == "" {
+			continue
+		}
+		group, err := stateStore.GetAlarmGroup(ctx, key)
+		if err != nil {
+			slog.WarnContext(ctx, "Loading the alarm group failed", "group", key, "error", err)
+			continue
+		}
+		if group == nil || !group.Open() {
+			continue
+		}
+
+		firing := []string{}
+		for _, alarmARN := range group.Firing {
+			if alarmARN != n.cloudWatchAlarmEvent.AlarmARN {
+				firing = append(firing, alarmARN)
+			}
+		}
+		if len(firing) == len(group.Firing) {
+			continue
+		}
+		group.Firing = firing
+
+		if !group.Open() {
+			group.Closed = time.Now()
+			if notificationsEnabled(ctx) {
+				resolveIncident(ctx, *group)
+			}
+		}
+		if err := stateStore.PutAlarmGroup(ctx, *group); err != nil {
+			slog.WarnContext(ctx, "Saving the alarm group failed", "group", key, "error", err)
+		}
+	}
+}
+
+// resolveIncident posts the resolution summary in the incident's thread, or the monitor channel when there isn't one
+func resolveIncident(ctx context.Context, group AlarmGroup) {
+	slog.InfoContext(ctx, "Closing incident", "tag", correlationTag, "group", group.Key)
+	summary := slack.Attachment{
+		Color: "good",
+		Title: fmt.Sprintf(":white_check_mark: Incident for %s %s resolved after %s", correlationTag, group.Key,
+			group.Closed.Sub(group.Opened).Round(time.Minute)),
+		Text: fmt.Sprintf("%d alarms: %s", len(group.Alarms), strings.Join(group.Alarms, ", ")),
+		Ts:   time.Now().Unix(),
+	}
+
+	if currentSlackBotToken() != "" && group.Ts != "" {
+		resp, err := postMessage(ctx, SlackMessage{
+			Channel:        group.Channel,
+			ThreadTs:       group.Ts,
+			ReplyBroadcast: true,
+			Attachments:    []slack.Attachment{summary},
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Posting the incident resolution failed", "group", group.Key, "error", err)
+		} else {
+			slog.InfoContext(ctx, "Posted the incident resolution", "group", group.Key, "ts", resp.Ts)
+		}
+		return
+	}
+	postAttachments(ctx, slackMonitorChannel, []slack.Attachment{summary})
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// currencySymbols symbol written in front of amounts in each currency Cost Explorer reports in
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"CNY": "¥",
+	"INR": "₹",
+}
+
+// CostAnomaly a Cost Anomaly Detection alert
+type CostAnomaly struct {
+	AccountID          string `json:"accountId"`
+	AnomalyID          string `json:"anomalyId"`
+	AnomalyStartDate   string `json:"anomalyStartDate"`
+	AnomalyEndDate     string `json:"anomalyEndDate"`
+	AnomalyDetailsLink string `json:"anomalyDetailsLink"`
+	DimensionalValue   string `json:"dimensionalValue"`
+	MonitorArn         string `json:"monitorArn"`
+	SubscriptionName   string `json:"subscriptionName"`
+	CurrencyCode       string `json:"currencyCode"`
+	Impact             struct {
+		MaxImpact             float64 `json:"maxImpact"`
+		TotalActualSpend      float64 `json:"totalActualSpend"`
+		TotalExpectedSpend    float64 `json:"totalExpectedSpend"`
+		TotalImpact           float64 `json:"totalImpact"`
+		TotalImpactPercentage float64 `json:"totalImpactPercentage"`
+	} `json:"impact"`
+	RootCauses []struct {
+		LinkedAccount     string `json:"linkedAccount"`
+		LinkedAccountName string `json:"linkedAccountName"`
+		Region            string `json:"region"`
+		Service           string `json:"service"`
+		UsageType         string `json:"usageType"`
+	} `json:"rootCauses"`
+}
+
+// amount the value in the anomaly's currency, which is USD unless the alert says otherwise
+func (anomaly CostAnomaly) amount(value float64) Amount {
+	code := anomaly.CurrencyCode
+	if code == "" {
+		code = "USD"
+	}
+	return Amount{Value: value, Symbol: currencySymbols[code], Code: code}
+}
+
+// costAnomalyMessage renders a Cost Anomaly Detection alert with its impact and root causes for the finance channel
+func costAnomalyMessage(sns events.SNSEntity) (*OtherMessage, error) {
+	anomaly := CostAnomaly{}
+	if err := json.Unmarshal([]byte(sns.Message), &anomaly); err != nil {
+		return nil, err
+	}
+
+	rootCauses := []string{}
+	for _, cause := range anomaly.RootCauses {
+		account := cause.LinkedAccount
+		if cause.LinkedAccountName != "" {
+			account = fmt.Sprintf("%s (%s)", cause.LinkedAccountName, cause.LinkedAccount)
+		}
+		parts := []string{}
+		for _, part := range []string{cause.Service, account, cause.Region, cause.UsageType} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		rootCauses = append(rootCauses, strings.Join(parts, " / "))
+	}
+
+	text := fmt.Sprintf("%s spend is %s against %s expected, %s (%.0f%%) over",
+		anomaly.DimensionalValue,
+		anomaly.amount(anomaly.Impact.TotalActualSpend),
+		anomaly.amount(anomaly.Impact.TotalExpectedSpend),
+		anomaly.amount(anomaly.Impact.TotalImpact),
+		anomaly.Impact.TotalImpactPercentage)
+	if anomaly.AnomalyDetailsLink != "" {
+		text = fmt.Sprintf("%s\n<%s|Anomaly Details>", text, anomaly.AnomalyDetailsLink)
+	}
+
+	fields := []slack.AttachmentField{
+		{Title: "Total Impact", Value: anomaly.amount(anomaly.Impact.TotalImpact).String(), Short: true},
+		{Title: "Max Daily Impact", Value: anomaly.amount(anomaly.Impact.MaxImpact).String(), Short: true},
+		{Title: "AccountID", Value: anomaly.AccountID, Short: true},
+		{Title: "Since", Value: anomaly.AnomalyStartDate, Short: true},
+	}
+	if len(rootCauses) > 0 {
+		fields = append(fields, slack.AttachmentField{Title: "Root Causes", Value: strings.Join(rootCauses, "\n"), Short: false})
+	}
+
+	return &OtherMessage{
+		Channel: financeChannel,
+		Attachment: slack.Attachment{
+			Color:           "danger",
+			Title:           fmt.Sprintf(":chart_with_upwards_trend: Cost anomaly in %s", anomaly.DimensionalValue),
+			Text:            text,
+			Ts:              time.Now().Unix(),
+			AttachmentField: fields,
+		},
+	}, nil
+}
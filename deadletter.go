@@ -0,0 +1,189 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// DeadLetter a notification that couldn't be delivered, as it's written to the DEAD_LETTER_QUEUE to be replayed
+type DeadLetter struct {
+	Subject     string               `json:"subject"`
+	Event       CloudWatchAlarmEvent `json:"event"`
+	Destination string               `json:"destination"`
+	Error       string               `json:"error"`
+	FailedAt    time.Time            `json:"failedAt"`
+}
+
+// settleFailures deals with the notifications that failed for sources that don't redeliver failed records
+// themselves.  They're forgotten so a redelivery isn't dropped as a duplicate, then with PROPAGATE_ERRORS the error is
+// returned so Lambda retries the invocation, otherwise they're dead lettered.
+func settleFailures(ctx context.Context, notifications []*notification) error {
+	var err error
+	for _, n := range notifications {
+		if n.failed != nil {
+			forgetDelivery(ctx, n)
+			err = n.failed
+		}
+	}
+	if !propagateErrors {
+		deadLetter(ctx, notifications)
+		return nil
+	}
+	return err
+}
+
+// forgetDelivery lets the retry of a notification that failed back through deduplication, its message and transition
+// were marked processed on the way in
+func forgetDelivery(ctx context.Context, n *notification) {
+	if stateStore == nil {
+		return
+	}
+	forgetMessage(ctx, n.messageID)
+	if err := stateStore.ForgetProcessed(ctx, transitionKey(n.cloudWatchAlarmEvent)); err != nil {
+		slog.WarnContext(ctx, "Forgetting the processed transition failed", "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "error", err)
+	}
+}
+
+// deadLetter writes the notifications that failed to the DEAD_LETTER_QUEUE.  Sources that redeliver failed records
+// themselves, SQS and Kinesis, don't need this.
+func deadLetter(ctx context.Context, notifications []*notification) {
+	if deadLetterQueue == "" {
+		return
+	}
+	client, err := sqsService()
+	if err != nil {
+		slog.ErrorContext(ctx, "Dead lettering failed", "error", err)
+		return
+	}
+	for _, n := range notifications {
+		if n.failed == nil {
+			continue
+		}
+		body, err := json.Marshal(DeadLetter{
+			Subject:     n.subject,
+			Event:       n.cloudWatchAlarmEvent,
+			Destination: n.destination,
+			Error:       n.failed.Error(),
+			FailedAt:    time.Now(),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Dead lettering failed", "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "message_id", n.messageID, "error", err)
+			continue
+		}
+		if _, err := client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+			QueueUrl:    aws.String(deadLetterQueue),
+			MessageBody: aws.String(string(body)),
+		}); err != nil {
+			slog.ErrorContext(ctx, "Dead lettering failed", "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "message_id", n.messageID, "error", err)
+			continue
+		}
+		slog.WarnContext(ctx, "Dead lettered notification", "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "message_id", n.messageID, "destination", n.destination)
+	}
+}
+
+// replayDeadLetters notifies the alarms in the DEAD_LETTER_QUEUE again, deleting the ones that get through.  The rest
+// are left for the next replay once their visibility timeout is up.
+func replayDeadLetters(ctx context.Context) error {
+	if deadLetterQueue == "" {
+		return fmt.Errorf("replaying requires DEAD_LETTER_QUEUE to be configured")
+	}
+	client, err := sqsService()
+	if err != nil {
+		return err
+	}
+
+	replayed := 0
+	for {
+		output, err := client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(deadLetterQueue),
+			MaxNumberOfMessages: aws.Int64(10),
+		})
+		if err != nil {
+			return err
+		}
+		if len(output.Messages) == 0 {
+			break
+		}
+
+		receipts := map[string]*string{}
+		deliveries := []alarmDelivery{}
+		for _, message := range output.Messages {
+			id := aws.StringValue(message.MessageId)
+			receipts[id] = message.ReceiptHandle
+
+			letter := DeadLetter{}
+			if err := json.Unmarshal([]byte(aws.StringValue(message.Body)), &letter); err != nil {
+				quarantine(ctx, "deadletter", id, []byte(aws.StringValue(message.Body)), err)
+				continue
+			}
+			// An alarm that has changed state since is left alone, the stale transition is deleted without being posted
+			if superseded(ctx, letter.Event) {
+				slog.InfoContext(ctx, "Dropping superseded dead letter", "alarm_arn", letter.Event.AlarmARN, "state", letter.Event.NewStateValue)
+				continue
+			}
+			// The transition was marked processed the first time through
+			if stateStore != nil {
+				if err := stateStore.ForgetProcessed(ctx, transitionKey(letter.Event)); err != nil {
+					slog.WarnContext(ctx, "Forgetting the processed transition failed", "alarm_arn", letter.Event.AlarmARN, "error", err)
+				}
+			}
+			deliveries = append(deliveries, alarmDelivery{
+				id:                   id,
+				sns:                  events.SNSEntity{Subject: letter.Subject},
+				cloudWatchAlarmEvent: letter.Event,
+			})
+		}
+
+		for _, n := range notifyAlarms(ctx, deliveries) {
+			if n.failed != nil {
+				delete(receipts, n.deliveryID)
+			}
+		}
+		for id, receipt := range receipts {
+			if _, err := client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(deadLetterQueue),
+				ReceiptHandle: receipt,
+			}); err != nil {
+				slog.WarnContext(ctx, "Deleting the dead letter failed", "sqs_message_id", id, "error", err)
+				continue
+			}
+			replayed++
+		}
+	}
+	slog.InfoContext(ctx, "Replayed dead letters", "replayed", replayed)
+	return nil
+}
+
+// superseded whether the state store has the alarm transitioning after the event did
+func superseded(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent) bool {
+	if stateStore == nil {
+		return false
+	}
+	alarmState, err := stateStore.GetAlarmState(ctx, cloudWatchAlarmEvent.AlarmARN)
+	if err != nil {
+		slog.WarnContext(ctx, "Loading the alarm state failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+		return false
+	}
+	return alarmState != nil && stateChangeTime(cloudWatchAlarmEvent).Before(alarmState.TransitionTime)
+}
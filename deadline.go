@@ -0,0 +1,75 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// sendBudget the context to send with, which ends DEADLINE_MARGIN before the invocation's own deadline so whatever
+// doesn't get sent in time can still be dead lettered rather than the function being killed mid-send.  DISPATCH_BUDGET
+// caps it sooner than that.
+func sendBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if ok {
+		deadline = deadline.Add(-deadlineMargin)
+	}
+	if budget := time.Now().Add(dispatchBudget); dispatchBudget > 0 && (!ok || budget.Before(deadline)) {
+		deadline, ok = budget, true
+	}
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// webhookClient a Slack incoming webhook, posted to through httpClient so a send can be cancelled with its context
+type webhookClient struct {
+	url string
+}
+
+// sendWebhook posts the payload to the webhook, cancelled along with the context so a send that's given up on can't
+// still go through after it's been dead lettered.  Slack answers a revoked or mistyped webhook with a 4xx rather than an
+// error, which is turned into one.
+func sendWebhook(ctx context.Context, client *webhookClient, payload slack.Payload) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"path"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// dependencySuppression the root cause alarm currently in ALARM that the alarm is declared a symptom of, empty if there
+// isn't one.  ALARM_DEPENDENCIES maps root cause alarm names to globs of their symptom alarms, e.g.
+// {"elb-unhealthy-hosts": ["instance-*"]}.
+func dependencySuppression(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent) (string, error) {
+	parents := []*string{}
+	for parent, patterns := range alarmDependencies {
+		if parent == cloudWatchAlarmEvent.AlarmName {
+			continue
+		}
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, cloudWatchAlarmEvent.AlarmName); matched {
+				parents = append(parents, aws.String(parent))
+				break
+			}
+		}
+	}
+	if len(parents) == 0 {
+		return "", nil
+	}
+
+	client, err := cloudWatchService()
+	if err != nil {
+		return "", err
+	}
+	output, err := client.DescribeAlarmsWithContext(ctx, &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: parents,
+		StateValue: aws.String(cloudwatch.StateValueAlarm),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	firing := []string{}
+	for _, alarm := range output.MetricAlarms {
+		firing = append(firing, aws.StringValue(alarm.AlarmName))
+	}
+	if len(firing) == 0 {
+		return "", nil
+	}
+	sort.Strings(firing)
+	return firing[0], nil
+}
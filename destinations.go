@@ -0,0 +1,325 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// Kinds of destination
+const (
+	destinationSlack     = "slack"
+	destinationTeams     = "teams"
+	destinationPagerDuty = "pagerduty"
+)
+
+// pagerDutyEventsURL the PagerDuty Events API v2 endpoint
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Destination somewhere notifications are sent besides SLACK_WEBHOOK and TEAMS_WEBHOOK, configured by the DESTINATIONS
+// JSON array.  Credentials is the Slack or Teams webhook URL or the PagerDuty routing key, or a reference to where it's
+// kept: ssm:<parameter>, secretsmanager:<secret id> or env:<env var>.  Only alarms at least as urgent as MinSeverity are
+// sent to it, and it has Timeout rather than DESTINATION_TIMEOUT to be sent to when that's set.
+type Destination struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Credentials string `json:"credentials"`
+	Channel     string `json:"channel,omitempty"`
+	MinSeverity string `json:"min_severity,omitempty"`
+	Timeout     string `json:"timeout,omitempty"`
+
+	minSeverity Severity
+	timeout     time.Duration
+}
+
+// parseDestinations parses the DESTINATIONS setting
+func parseDestinations(value string) ([]Destination, error) {
+	parsed := []Destination{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, err
+	}
+	for i := range parsed {
+		destination := &parsed[i]
+		if destination.Name == "" {
+			return nil, fmt.Errorf("destination %d has no name", i)
+		}
+		switch destination.Type {
+		case destinationSlack, destinationTeams, destinationPagerDuty:
+		default:
+			return nil, fmt.Errorf("destination %s: unknown type %q", destination.Name, destination.Type)
+		}
+		if destination.Credentials == "" {
+			return nil, fmt.Errorf("destination %s has no credentials", destination.Name)
+		}
+		if destination.MinSeverity != "" {
+			severity, err := ParseSeverity(destination.MinSeverity)
+			if err != nil {
+				return nil, fmt.Errorf("destination %s: %s", destination.Name, err)
+			}
+			destination.minSeverity = severity
+		}
+		if destination.Timeout != "" {
+			timeout, err := time.ParseDuration(destination.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("destination %s: %s", destination.Name, err)
+			}
+			destination.timeout = timeout
+		}
+	}
+	return parsed, nil
+}
+
+// sendTimeout how long sending to the destination has, DESTINATION_TIMEOUT unless it has its own timeout
+func (destination Destination) sendTimeout() time.Duration {
+	if destination.timeout > 0 {
+		return destination.timeout
+	}
+	return destinationTimeout
+}
+
+// wants whether the destination is sent the notification, when the notification's severity limits its destinations
+// the destination has to be one of them
+func (destination Destination) wants(n *notification) bool {
+	if names := severityOverride(n.severity).Destinations; len(names) > 0 {
+		for _, name := range names {
+			if name == destination.Name {
+				return true
+			}
+		}
+		return false
+	}
+	return destination.minSeverity == 0 || n.severity <= destination.minSeverity
+}
+
+// send sends the notifications the destination wants to it, returning the last error
+func (destination Destination) send(ctx context.Context, notifications []*notification) error {
+	wanted := []*notification{}
+	for _, n := range notifications {
+		if destination.wants(n) {
+			wanted = append(wanted, n)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+	return destination.deliver(ctx, wanted)
+}
+
+// deliver sends the notifications to the destination whether it wants them or not, returning the last error
+func (destination Destination) deliver(ctx context.Context, notifications []*notification) error {
+	// Failed notifications are marked so their records are retried or dead lettered like a failed Slack post
+	label := destination.Type + ":" + destination.Name
+	fail := func(n *notification, err error) {
+		slog.ErrorContext(withAlarms(ctx, n.cloudWatchAlarmEvent.AlarmARN), "Sending notification failed", "destination", label, "error", err)
+		n.markFailed(err, label)
+	}
+
+	credentials, err := resolveCredentials(ctx, destination.Credentials)
+	if err != nil {
+		err = fmt.Errorf("destination %s: %s", destination.Name, err)
+		for _, n := range notifications {
+			fail(n, err)
+		}
+		return err
+	}
+
+	var sendErr error
+	switch destination.Type {
+	case destinationSlack:
+		client := &webhookClient{url: credentials}
+		// Chunks keep the notifications' order, so each one's notifications are the next ones along
+		offset := 0
+		for _, chunk := range chunkAttachments(attachments(notifications)) {
+			chunked := notifications[offset : offset+len(chunk)]
+			offset += len(chunk)
+			payload := slack.Payload{Channel: destination.Channel, Attachments: chunk}
+			started := time.Now()
+			resp, err := sendWebhook(ctx, client, payload)
+			auditDelivery(ctx, "slack-webhook:"+destination.Name, payload, resp, err, started)
+			if err != nil {
+				for _, n := range chunked {
+					fail(n, err)
+				}
+				sendErr = err
+			}
+		}
+	case destinationTeams:
+		for _, n := range notifications {
+			ctx := withAlarms(ctx, n.cloudWatchAlarmEvent.AlarmARN)
+			if _, err := postTeams(ctx, credentials, "teams:"+destination.Name, adaptiveCard(n)); err != nil {
+				fail(n, err)
+				sendErr = err
+			}
+		}
+	case destinationPagerDuty:
+		for _, n := range notifications {
+			ctx := withAlarms(ctx, n.cloudWatchAlarmEvent.AlarmARN)
+			if err := sendPagerDuty(ctx, credentials, destination.Name, n); err != nil {
+				fail(n, err)
+				sendErr = err
+			}
+		}
+	}
+	return sendErr
+}
+
+// attachments the Slack attachments of the notifications
+func attachments(notifications []*notification) []slack.Attachment {
+	slackAttachments := []slack.Attachment{}
+	for _, n := range notifications {
+		slackAttachments = append(slackAttachments, n.slackAttachment)
+	}
+	return slackAttachments
+}
+
+// pagerDutySeverities the PagerDuty severity of each severity
+var pagerDutySeverities = map[Severity]string{
+	SeverityCritical: "critical",
+	SeverityHigh:     "error",
+	SeverityWarning:  "warning",
+	SeverityInfo:     "info",
+}
+
+// PagerDutyEvent https://developer.pagerduty.com/docs/events-api-v2/trigger-events/
+type PagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *PagerDutyPayload `json:"payload,omitempty"`
+}
+
+// PagerDutyPayload what the PagerDuty alert says about the alarm
+type PagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Component     string                 `json:"component,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+// sendPagerDuty triggers a PagerDuty alert for an ALARM, deduplicated by the alarm so an OK resolves it
+func sendPagerDuty(ctx context.Context, routingKey string, name string, n *notification) error {
+	event := PagerDutyEvent{
+		RoutingKey:  routingKey,
+		EventAction: "trigger",
+		DedupKey:    n.cloudWatchAlarmEvent.AlarmARN,
+	}
+	switch n.cloudWatchAlarmEvent.NewStateValue {
+	case "ALARM":
+		event.Payload = &PagerDutyPayload{
+			Summary:   n.subject,
+			Source:    n.cloudWatchAlarmEvent.AlarmARN,
+			Severity:  pagerDutySeverities[n.severity],
+			Component: n.cloudWatchAlarmEvent.Trigger.Namespace,
+			CustomDetails: map[string]interface{}{
+				"reason":  n.cloudWatchAlarmEvent.NewStateReason,
+				"account": n.cloudWatchAlarmEvent.AWSAccountID,
+				"region":  n.cloudWatchAlarmEvent.Region,
+				"runbook": n.runbook,
+			},
+		}
+	case "OK":
+		event.EventAction = "resolve"
+	default:
+		return nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, pagerDutyEventsURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	// The routing key isn't audited
+	audited := event
+	audited.RoutingKey = ""
+	started := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		auditDelivery(ctx, "pagerduty:"+name, audited, nil, err, started)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		err = fmt.Errorf("pagerduty returned %s", resp.Status)
+	}
+	auditDelivery(ctx, "pagerduty:"+name, audited, resp.Status, err, started)
+	return err
+}
+
+var (
+	credentialsCache      = map[string]cachedCredentials{}
+	credentialsCacheMutex sync.Mutex
+)
+
+// cachedCredentials credentials fetched from SSM or Secrets Manager, kept for SECRETS_REFRESH.  Reloaded ones are
+// fetched again skipping the Parameters and Secrets extension's cache.
+type cachedCredentials struct {
+	value    string
+	fetched  time.Time
+	reloaded bool
+}
+
+// resolveCredentials the credentials the reference points at, or the reference itself when it's the credentials
+func resolveCredentials(ctx context.Context, reference string) (string, error) {
+	kind, name, ok := strings.Cut(reference, ":")
+	switch {
+	case ok && kind == "env":
+		return getenv(name), nil
+	case ok && (kind == "ssm" || kind == "secretsmanager"):
+	default:
+		return reference, nil
+	}
+
+	credentialsCacheMutex.Lock()
+	cached, found := credentialsCache[reference]
+	credentialsCacheMutex.Unlock()
+	if found && !cached.reloaded && time.Since(cached.fetched) < secretsRefresh {
+		return cached.value, nil
+	}
+	if found && cached.reloaded {
+		ctx = bypassSecretsExtension(ctx)
+	}
+
+	var value string
+	var err error
+	if kind == "ssm" {
+		value, err = secretParameter(ctx, name)
+	} else {
+		value, err = secretsManagerValue(ctx, name, "")
+	}
+	if err != nil {
+		return "", err
+	}
+	credentialsCacheMutex.Lock()
+	credentialsCache[reference] = cachedCredentials{value: value, fetched: time.Now()}
+	credentialsCacheMutex.Unlock()
+	return value, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	// Slack will accept far more, but anything past a few thousand characters is unreadable in a channel
+	slackTextLimit = 3000
+	// Slack rejects posts much bigger than this with a 400 or 413
+	slackPayloadLimit = 40000
+	// Longest expiry allowed for a SigV4 presigned URL
+	detailsLinkMaxExpiry = 7 * 24 * time.Hour
+)
+
+var detailsTemplate = template.Must(template.New("details").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body>
+<h1>{{.Subject}}</h1>
+<table>
+<tr><th align="left">Alarm</th><td>{{.Event.AlarmName}}</td></tr>
+<tr><th align="left">Description</th><td>{{.Event.AlarmDescription}}</td></tr>
+<tr><th align="left">State</th><td>{{.Event.OldStateValue}} &rarr; {{.Event.NewStateValue}}</td></tr>
+<tr><th align="left">Changed</th><td>{{.Event.StateChangeTime}}</td></tr>
+<tr><th align="left">AccountID</th><td>{{.Event.AWSAccountID}}</td></tr>
+<tr><th align="left">Region</th><td>{{.Event.Region}}</td></tr>
+</table>
+<pre style="white-space: pre-wrap">{{.Event.NewStateReason}}</pre>
+</body>
+</html>
+`))
+
+// truncate shortens text to at most limit runes, preferring to cut on whitespace, and reports whether anything was cut
+func truncate(text string, limit int) (string, bool) {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text, false
+	}
+
+	cut := limit - 1
+	for i := cut; i > limit/2; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + "…", true
+}
+
+// publishDetails renders the full alarm event to an HTML page in the details bucket and returns a link to it.  The link
+// is under DETAILS_URL when it's set, for a bucket served by CloudFront or the like, otherwise it's presigned for
+// DETAILS_LINK_EXPIRY, by default the longest a presigned URL can last.  A presigned URL stops working once the
+// function's role session it was signed with expires, whatever its expiry, so links that have to last should use
+// DETAILS_URL.
+func publishDetails(ctx context.Context, subject string, cloudWatchAlarmEvent CloudWatchAlarmEvent) (string, error) {
+	page := bytes.Buffer{}
+	err := detailsTemplate.Execute(&page, struct {
+		Subject string
+		Event   CloudWatchAlarmEvent
+	}{subject, cloudWatchAlarmEvent})
+	if err != nil {
+		return "", err
+	}
+
+	client, err := s3Service()
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("details/%s/%d.html", cloudWatchAlarmEvent.AlarmName, time.Now().UnixNano())
+	_, err = client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(detailsBucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(page.Bytes()),
+		ContentType: aws.String("text/html; charset=utf-8"),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if detailsURL != "" {
+		return detailsURL + "/" + key, nil
+	}
+	req, _ := client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(detailsBucket),
+		Key:    aws.String(key),
+	})
+	return req.Presign(detailsLinkExpiry)
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		limit     int
+		truncated string
+		cut       bool
+	}{
+		{"short", "disk full", 20, "disk full", false},
+		{"at the limit", "abcde", 5, "abcde", false},
+		{"on whitespace", "the quick brown fox", 12, "the quick…", true},
+		{"no whitespace", "abcdefghij", 5, "abcd…", true},
+		{"runes", "héllo wörld", 8, "héllo…", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			truncated, cut := truncate(test.text, test.limit)
+			if truncated != test.truncated || cut != test.cut {
+				t.Errorf("truncate(%q, %d) = %q, %t, want %q, %t", test.text, test.limit, truncated, cut, test.truncated, test.cut)
+			}
+		})
+	}
+}
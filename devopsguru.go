@@ -0,0 +1,114 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// devOpsGuruSeverityColors attachment color for each DevOps Guru insight severity
+var devOpsGuruSeverityColors = map[string]string{
+	"high":   "danger",
+	"medium": "warning",
+	"low":    "#439FE0",
+}
+
+// DevOpsGuruNotification a DevOps Guru insight notification
+type DevOpsGuruNotification struct {
+	AccountID          string `json:"accountId"`
+	Region             string `json:"region"`
+	MessageType        string `json:"messageType"`
+	InsightID          string `json:"insightId"`
+	InsightURL         string `json:"insightUrl"`
+	InsightType        string `json:"insightType"`
+	InsightDescription string `json:"insightDescription"`
+	InsightSeverity    string `json:"insightSeverity"`
+	Anomalies          []struct {
+		ID            string `json:"id"`
+		SourceDetails []struct {
+			DataSource      string `json:"dataSource"`
+			DataIdentifiers struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"dataIdentifiers"`
+		} `json:"sourceDetails"`
+		AssociatedResourceArns []string `json:"associatedResourceArns"`
+	} `json:"anomalies"`
+	Events []struct {
+		Name        string `json:"name"`
+		EventSource string `json:"eventSource"`
+		EventClass  string `json:"eventClass"`
+	} `json:"events"`
+}
+
+// devOpsGuruMessage renders a DevOps Guru insight with its severity, the services its anomalies were found in and the
+// events related to it
+func devOpsGuruMessage(sns events.SNSEntity) (*OtherMessage, error) {
+	notification := DevOpsGuruNotification{}
+	if err := json.Unmarshal([]byte(sns.Message), &notification); err != nil {
+		return nil, err
+	}
+
+	services := []string{}
+	for _, anomaly := range notification.Anomalies {
+		for _, source := range anomaly.SourceDetails {
+			if namespace := source.DataIdentifiers.Namespace; namespace != "" {
+				services = appendUnique(services, namespace)
+			}
+		}
+	}
+	relatedEvents := []string{}
+	for _, event := range notification.Events {
+		relatedEvents = append(relatedEvents, fmt.Sprintf("%s (%s)", event.Name, event.EventSource))
+	}
+
+	severity := strings.ToLower(notification.InsightSeverity)
+	color, ok := devOpsGuruSeverityColors[severity]
+	if !ok || notification.MessageType == "CLOSED_INSIGHT" {
+		color = "good"
+	}
+	text := notification.InsightDescription
+	if notification.InsightURL != "" {
+		text = fmt.Sprintf("%s\n<%s|DevOps Guru>", text, notification.InsightURL)
+	}
+
+	attachmentFields := []slack.AttachmentField{
+		{Title: "Severity", Value: severity, Short: true},
+		{Title: "Type", Value: notification.InsightType, Short: true},
+		{Title: "AccountID", Value: notification.AccountID, Short: true},
+		{Title: "Region", Value: notification.Region, Short: true},
+	}
+	if len(services) > 0 {
+		attachmentFields = append(attachmentFields, slack.AttachmentField{Title: "Anomalous Services", Value: strings.Join(services, ", "), Short: false})
+	}
+	if len(relatedEvents) > 0 {
+		value, _ := truncate(strings.Join(relatedEvents, "\n"), healthResourcesLimit)
+		attachmentFields = append(attachmentFields, slack.AttachmentField{Title: "Related Events", Value: value, Short: false})
+	}
+
+	return &OtherMessage{Attachment: slack.Attachment{
+		Color:           color,
+		Title:           fmt.Sprintf(":crystal_ball: DevOps Guru %s: %s", notification.MessageType, notification.InsightID),
+		Text:            text,
+		Ts:              time.Now().Unix(),
+		AttachmentField: attachmentFields,
+	}}, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// emfMutex keeps metric lines from interleaving with each other when destinations are sent to concurrently
+var emfMutex sync.Mutex
+
+// emitMetric writes the metric to stdout in CloudWatch embedded metric format, which Lambda turns into a metric in
+// METRICS_NAMESPACE without a PutMetricData call.  Nothing is written unless EMF_METRICS is set.  With TRACING set to
+// otel it's recorded with OpenTelemetry as well.
+func emitMetric(name string, unit string, value float64, dimensions map[string]string) {
+	if tracing == tracingOTel {
+		otelMetric(name, unit, value, dimensions)
+	}
+	if !emfMetrics {
+		return
+	}
+
+	names := []string{}
+	line := map[string]interface{}{name: value}
+	for dimension, dimensionValue := range dimensions {
+		names = append(names, dimension)
+		line[dimension] = dimensionValue
+	}
+	sort.Strings(names)
+	line["_aws"] = map[string]interface{}{
+		"Timestamp": time.Now().UnixNano() / int64(time.Millisecond),
+		"CloudWatchMetrics": []map[string]interface{}{{
+			"Namespace":  metricsNamespace,
+			"Dimensions": [][]string{names},
+			"Metrics":    []map[string]string{{"Name": name, "Unit": unit}},
+		}},
+	}
+
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		slog.Warn("Encoding the metric failed", "metric", name, "error", err)
+		return
+	}
+	emfMutex.Lock()
+	defer emfMutex.Unlock()
+	fmt.Fprintln(os.Stdout, string(encoded))
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// escalateUnacknowledged re-notifies, with the escalation mentions and through the escalation webhook, every alarm
+// that has been in ALARM longer than the escalation SLA without being acknowledged.  A reaction on the alarm's
+// message counts as an acknowledgement.  Each ALARM is only escalated once, and only if it was notified and isn't
+// suppressed now.
+func escalateUnacknowledged(ctx context.Context) error {
+	alarmStates, err := stateStore.AlarmStates(ctx)
+	if err != nil {
+		return err
+	}
+	suppressions, err := currentSuppressions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, alarmState := range alarmStates {
+		if alarmState.State != "ALARM" || alarmState.Pending || alarmState.Escalated || alarmState.AcknowledgedBy != "" {
+			continue
+		}
+		if time.Since(alarmState.TransitionTime) < escalationSLA {
+			continue
+		}
+		if reason := reminderSuppressed(ctx, alarmState, suppressions); reason != "" {
+			slog.InfoContext(ctx, "Not escalating", "alarm_arn", alarmState.AlarmARN, "reason", reason)
+			continue
+		}
+
+		if currentSlackBotToken() != "" && alarmState.MessageTs != "" {
+			user, err := firstReaction(ctx, alarmState.Channel, alarmState.MessageTs)
+			if err != nil {
+				slog.WarnContext(ctx, "Checking for reactions failed", "alarm_arn", alarmState.AlarmARN, "error", err)
+			} else if user != "" {
+				alarmState.AcknowledgedBy = user
+				alarmState.AcknowledgedAt = time.Now()
+				if err := stateStore.PutAlarmState(ctx, alarmState); err != nil {
+					slog.ErrorContext(ctx, "Saving the alarm state failed", "alarm_arn", alarmState.AlarmARN, "error", err)
+				}
+				continue
+			}
+		}
+
+		escalate(ctx, alarmState)
+		alarmState.Escalated = true
+		if err := stateStore.PutAlarmState(ctx, alarmState); err != nil {
+			slog.ErrorContext(ctx, "Saving the alarm state failed", "alarm_arn", alarmState.AlarmARN, "error", err)
+		}
+	}
+	return nil
+}
+
+// escalate posts the escalation for the alarm in the alarm's thread, or the channel it's routed to when there isn't one,
+// and to the escalation webhook
+func escalate(ctx context.Context, alarmState AlarmState) {
+	text := strings.TrimSpace(fmt.Sprintf("%s :rotating_light: *%s* has been in ALARM for %s without being acknowledged. <%s|Console>",
+		escalationMentions, alarmState.AlarmName, time.Since(alarmState.TransitionTime).Round(time.Minute), consoleURL(alarmState.Event)))
+	slog.InfoContext(ctx, "Escalating", "alarm_arn", alarmState.AlarmARN, "alarm_name", alarmState.AlarmName)
+	ctx = withAlarms(ctx, alarmState.AlarmARN)
+
+	if currentSlackBotToken() != "" {
+		channel := alarmState.Channel
+		if channel == "" {
+			channel = ownChannel(alarmState.Event, alarmSeverity(alarmState.Event))
+		}
+		resp, err := postMessage(ctx, SlackMessage{
+			Channel:        channel,
+			ThreadTs:       alarmState.MessageTs,
+			ReplyBroadcast: alarmState.MessageTs != "",
+			Text:           text,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Posting the escalation failed", "destination", "slack:"+channel, "error", err)
+		} else {
+			slog.InfoContext(ctx, "Posted the escalation", "destination", "slack:"+channel, "ts", resp.Ts)
+		}
+	} else {
+		postAttachments(ctx, ownChannel(alarmState.Event, alarmSeverity(alarmState.Event)), []slack.Attachment{{Color: "danger", Text: text}})
+	}
+
+	if client := currentEscalationClient(); client != nil {
+		payload := slack.Payload{
+			Attachments: []slack.Attachment{{Color: "danger", Text: text}},
+		}
+		started := time.Now()
+		resp, err := sendWebhook(ctx, client, payload)
+		auditDelivery(ctx, "slack-webhook:escalation", payload, resp, err, started)
+		if err != nil {
+			slog.ErrorContext(ctx, "Posting the escalation failed", "destination", "slack-webhook:escalation", "error", err)
+		} else {
+			slog.InfoContext(ctx, "Posted the escalation", "destination", "slack-webhook:escalation", "status", resp.Status)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// eventBridgeAlarmStateChange the detail-type of the events CloudWatch sends EventBridge when an alarm changes state
+const eventBridgeAlarmStateChange = "CloudWatch Alarm State Change"
+
+// EventBridgeAlarmDetail the detail of a CloudWatch Alarm State Change event, which is also the alarm data an alarm
+// invoking the function as its action sends
+type EventBridgeAlarmDetail struct {
+	AlarmName     string                   `json:"alarmName"`
+	State         EventBridgeAlarmState    `json:"state"`
+	PreviousState EventBridgeAlarmState    `json:"previousState"`
+	Configuration EventBridgeAlarmSettings `json:"configuration"`
+}
+
+// EventBridgeAlarmState a state of the alarm in a CloudWatch Alarm State Change event
+type EventBridgeAlarmState struct {
+	Value     string `json:"value"`
+	Reason    string `json:"reason"`
+	Timestamp string `json:"timestamp"`
+}
+
+// EventBridgeAlarmSettings the configuration of the alarm in a CloudWatch Alarm State Change event
+type EventBridgeAlarmSettings struct {
+	Description string                   `json:"description"`
+	Metrics     []EventBridgeAlarmMetric `json:"metrics"`
+}
+
+// EventBridgeAlarmMetric one of the metrics, or metric math expressions, the alarm evaluates
+type EventBridgeAlarmMetric struct {
+	ID         string `json:"id"`
+	Expression string `json:"expression"`
+	MetricStat *struct {
+		Metric struct {
+			Namespace  string            `json:"namespace"`
+			Name       string            `json:"name"`
+			Dimensions map[string]string `json:"dimensions"`
+		} `json:"metric"`
+		Period int    `json:"period"`
+		Stat   string `json:"stat"`
+	} `json:"metricStat"`
+	ReturnData bool `json:"returnData"`
+}
+
+// HandleEventBridge function that handles events delivered straight from EventBridge without the SNS hop.  Anything
+// but an alarm state change is rendered the same as it would be coming through SNS.
+func HandleEventBridge(ctx context.Context, event events.CloudWatchEvent) error {
+	slog.InfoContext(ctx, "Processing EventBridge event", "detail_type", event.DetailType, "event_id", event.ID)
+
+	if event.DetailType != eventBridgeAlarmStateChange {
+		message, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if other := renderMessage(ctx, events.SNSEntity{Subject: event.DetailType, Message: string(message)}); other != nil {
+			return postOtherMessages(ctx, []OtherMessage{*other})
+		}
+		return nil
+	}
+
+	cloudWatchAlarmEvent, err := eventBridgeAlarmEvent(event)
+	if err != nil {
+		quarantine(ctx, "eventbridge", event.ID, event.Detail, err)
+		return err
+	}
+	return HandleAlarm(ctx, cloudWatchAlarmEvent)
+}
+
+// eventBridgeAlarmEvent converts the CloudWatch Alarm State Change event into the alarm event SNS would have delivered
+func eventBridgeAlarmEvent(event events.CloudWatchEvent) (CloudWatchAlarmEvent, error) {
+	detail := EventBridgeAlarmDetail{}
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		return CloudWatchAlarmEvent{}, err
+	}
+
+	alarmARN := ""
+	if len(event.Resources) != 0 {
+		alarmARN = event.Resources[0]
+	}
+	return detail.alarmEvent(alarmARN, event.AccountID, event.Region), nil
+}
+
+// eventBridgeAlarm decodes a CloudWatch Alarm State Change event into the alarm event SNS would have delivered
+func eventBridgeAlarm(sns events.SNSEntity) (CloudWatchAlarmEvent, error) {
+	event := events.CloudWatchEvent{}
+	if err := json.Unmarshal([]byte(sns.Message), &event); err != nil {
+		return CloudWatchAlarmEvent{}, err
+	}
+	return eventBridgeAlarmEvent(event)
+}
+
+// alarmEvent the alarm event SNS would have delivered for the alarm
+func (detail EventBridgeAlarmDetail) alarmEvent(alarmARN string, accountID string, region string) CloudWatchAlarmEvent {
+	cloudWatchAlarmEvent := CloudWatchAlarmEvent{
+		AlarmName:        detail.AlarmName,
+		AlarmARN:         alarmARN,
+		AlarmDescription: detail.Configuration.Description,
+		AWSAccountID:     accountID,
+		NewStateValue:    detail.State.Value,
+		NewStateReason:   detail.State.Reason,
+		StateChangeTime:  detail.State.Timestamp,
+		Region:           region,
+		OldStateValue:    detail.PreviousState.Value,
+	}
+
+	// Only single metric alarms have a trigger, metric math alarms are left without one just like over SNS
+	for _, metric := range detail.Configuration.Metrics {
+		if metric.MetricStat == nil || !metric.ReturnData {
+			continue
+		}
+		trigger := CloudWatchAlarmEventTrigger{
+			MetricName: metric.MetricStat.Metric.Name,
+			Namespace:  metric.MetricStat.Metric.Namespace,
+			Period:     metric.MetricStat.Period,
+		}
+		if strings.HasPrefix(metric.MetricStat.Stat, "p") {
+			trigger.ExtendedStatistic = metric.MetricStat.Stat
+		} else if metric.MetricStat.Stat == "SampleCount" {
+			trigger.Statistic = "SAMPLE_COUNT"
+		} else {
+			trigger.Statistic = strings.ToUpper(metric.MetricStat.Stat)
+		}
+
+		names := []string{}
+		for name := range metric.MetricStat.Metric.Dimensions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			trigger.Dimensions = append(trigger.Dimensions, CloudWatchAlarmEventDimension{Name: name, Value: metric.MetricStat.Metric.Dimensions[name]})
+		}
+
+		cloudWatchAlarmEvent.Trigger = trigger
+		break
+	}
+	return cloudWatchAlarmEvent
+}
+
+// AlarmActionEvent the payload of an alarm invoking the function directly as its alarm action
+type AlarmActionEvent struct {
+	Source    string                 `json:"source"`
+	AlarmARN  string                 `json:"alarmArn"`
+	AccountID string                 `json:"accountId"`
+	Time      string                 `json:"time"`
+	Region    string                 `json:"region"`
+	AlarmData EventBridgeAlarmDetail `json:"alarmData"`
+}
+
+// HandleAlarmAction function that handles alarms invoking the function as their action without an SNS topic
+func HandleAlarmAction(ctx context.Context, event AlarmActionEvent) error {
+	return HandleAlarm(ctx, event.AlarmData.alarmEvent(event.AlarmARN, event.AccountID, event.Region))
+}
+
+// alarmAction decodes the payload of an alarm invoking the function as its action into the alarm event SNS would have
+// delivered
+func alarmAction(sns events.SNSEntity) (CloudWatchAlarmEvent, error) {
+	event := AlarmActionEvent{}
+	if err := json.Unmarshal([]byte(sns.Message), &event); err != nil {
+		return CloudWatchAlarmEvent{}, err
+	}
+	return event.AlarmData.alarmEvent(event.AlarmARN, event.AccountID, event.Region), nil
+}
+
+// HandleAlarm function that handles an alarm event delivered without an SNS topic
+func HandleAlarm(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent) error {
+	slog.InfoContext(ctx, "Processing alarm", "alarm_arn", cloudWatchAlarmEvent.AlarmARN)
+
+	return settleFailures(ctx, notifyAlarms(ctx, []alarmDelivery{{
+		sns:                  events.SNSEntity{Subject: alarmSubject(cloudWatchAlarmEvent)},
+		cloudWatchAlarmEvent: cloudWatchAlarmEvent,
+	}}))
+}
+
+// alarmSubject the subject CloudWatch gives the SNS message for the alarm event
+func alarmSubject(cloudWatchAlarmEvent CloudWatchAlarmEvent) string {
+	return fmt.Sprintf("%s: \"%s\" in %s", cloudWatchAlarmEvent.NewStateValue, cloudWatchAlarmEvent.AlarmName, cloudWatchAlarmEvent.Region)
+}
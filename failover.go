@@ -0,0 +1,90 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+var (
+	slackFailoverClient *webhookClient
+	// primaryFailures the sends to SLACK_WEBHOOK that have failed in a row, and when the last of them did
+	primaryFailures     int
+	primaryFailedAt     time.Time
+	primaryFailureMutex sync.Mutex
+)
+
+// sendSlackWebhook sends the payload to SLACK_WEBHOOK, failing over to SLACK_FAILOVER_WEBHOOK once that many sends in a
+// row, SLACK_FAILOVER_AFTER, have failed.  While failed over the primary is skipped, other than one send each
+// SLACK_FAILOVER_COOLDOWN to see if it's back.
+func sendSlackWebhook(ctx context.Context, payload slack.Payload) (*http.Response, error) {
+	var resp *http.Response
+	client, err := slackWebhookClient()
+	if err == nil && !failedOver() {
+		started := time.Now()
+		resp, err = sendWebhook(ctx, client, payload)
+		auditDelivery(ctx, "slack-webhook:"+payload.Channel, payload, resp, err, started)
+		if rejectedCredentials(resp) && reloadSecrets(ctx) {
+			if client, err = slackWebhookClient(); err == nil {
+				started = time.Now()
+				resp, err = sendWebhook(ctx, client, payload)
+				auditDelivery(ctx, "slack-webhook:"+payload.Channel, payload, resp, err, started)
+			}
+		}
+		recordPrimary(err)
+		if err == nil || !failedOver() {
+			return resp, err
+		}
+	}
+	// A SLACK_WEBHOOK that isn't set or isn't a URL fails over straight away
+	failover := currentFailoverClient()
+	if failover == nil {
+		return resp, err
+	}
+
+	if err != nil {
+		slog.WarnContext(ctx, "Failing over to SLACK_FAILOVER_WEBHOOK", "error", err)
+	}
+	started := time.Now()
+	resp, err = sendWebhook(ctx, failover, payload)
+	auditDelivery(ctx, "slack-failover:"+payload.Channel, payload, resp, err, started)
+	return resp, err
+}
+
+// failedOver whether the primary webhook has failed enough times in a row to skip it, or to fail over after it's
+// failed.  It's tried again once the cooldown since its last failure is up.
+func failedOver() bool {
+	primaryFailureMutex.Lock()
+	defer primaryFailureMutex.Unlock()
+	return currentFailoverClient() != nil && primaryFailures >= slackFailoverAfter && time.Since(primaryFailedAt) < slackFailoverCooldown
+}
+
+// recordPrimary counts the send to the primary webhook towards failing over, or resets the count when it got through
+func recordPrimary(err error) {
+	primaryFailureMutex.Lock()
+	defer primaryFailureMutex.Unlock()
+	if err != nil {
+		primaryFailures++
+		primaryFailedAt = time.Now()
+	} else {
+		primaryFailures = 0
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// stillFiringDigest posts every alarm currently in ALARM, longest firing first, so alarms that have been ignored
+// resurface instead of scrolling away
+func stillFiringDigest(ctx context.Context) error {
+	firing, err := firingAlarms(ctx)
+	if err != nil {
+		return err
+	}
+	if len(firing) == 0 {
+		slog.InfoContext(ctx, "No alarms are in ALARM")
+		return nil
+	}
+
+	lines := []string{}
+	for _, alarmState := range firing {
+		line := fmt.Sprintf("• <%s|%s> for %s", consoleURL(CloudWatchAlarmEvent{AlarmARN: alarmState.AlarmARN, AlarmName: alarmState.AlarmName}),
+			alarmState.AlarmName, time.Since(alarmState.TransitionTime).Round(time.Minute))
+		if alarmState.AcknowledgedBy != "" {
+			line += fmt.Sprintf(", acknowledged by <@%s>", alarmState.AcknowledgedBy)
+		}
+		lines = append(lines, line)
+	}
+	text, _ := truncate(strings.Join(lines, "\n"), slackTextLimit)
+
+	postAttachments(ctx, slackMonitorChannel, []slack.Attachment{{
+		Color: "danger",
+		Title: fmt.Sprintf(":fire: %d alarms still in ALARM", len(firing)),
+		Text:  text,
+		Ts:    time.Now().Unix(),
+	}})
+	return nil
+}
+
+// firingAlarms every alarm currently in ALARM, longest firing first.  The state store knows who acknowledged what,
+// without one CloudWatch is asked.
+func firingAlarms(ctx context.Context) ([]AlarmState, error) {
+	firing := []AlarmState{}
+	if stateStore != nil {
+		alarmStates, err := stateStore.AlarmStates(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, alarmState := range alarmStates {
+			if alarmState.State == "ALARM" {
+				firing = append(firing, alarmState)
+			}
+		}
+	} else {
+		client, err := cloudWatchService()
+		if err != nil {
+			return nil, err
+		}
+		err = client.DescribeAlarmsPagesWithContext(ctx, &cloudwatch.DescribeAlarmsInput{
+			StateValue: aws.String(cloudwatch.StateValueAlarm),
+		}, func(output *cloudwatch.DescribeAlarmsOutput, lastPage bool) bool {
+			for _, alarm := range output.MetricAlarms {
+				firing = append(firing, AlarmState{
+					AlarmARN:       aws.StringValue(alarm.AlarmArn),
+					AlarmName:      aws.StringValue(alarm.AlarmName),
+					State:          aws.StringValue(alarm.StateValue),
+					TransitionTime: aws.TimeValue(alarm.StateUpdatedTimestamp),
+					// Enough of the event for the severity marker to be found
+					Event: CloudWatchAlarmEvent{
+						AlarmName:        aws.StringValue(alarm.AlarmName),
+						AlarmDescription: aws.StringValue(alarm.AlarmDescription),
+					},
+				})
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(firing, func(i, j int) bool {
+		return firing[i].TransitionTime.Before(firing[j].TransitionTime)
+	})
+	return firing, nil
+}
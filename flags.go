@@ -0,0 +1,60 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Features that can be switched off per environment with a FEATURE_<NAME> setting, e.g. FEATURE_THREADING=false.  Like
+// any other setting they can come from AppConfig, where a feature flag's enabled value is used, so a feature can be
+// rolled back without a deploy.  Every feature is on unless its flag says otherwise.
+const (
+	// featureEnrichment the runbook and metric history lookups made while rendering a notification
+	featureEnrichment = "enrichment"
+	// featureThreading posting correlated alarms and block details in threads
+	featureThreading = "threading"
+	// featureDigests holding notifications for the quiet hours digest
+	featureDigests = "digests"
+	// featureDestinations sending to DESTINATIONS
+	featureDestinations = "destinations"
+)
+
+var featureNames = []string{featureEnrichment, featureThreading, featureDigests, featureDestinations}
+
+// parseFeatures reads the FEATURE_<NAME> settings
+func parseFeatures() map[string]bool {
+	parsed := map[string]bool{}
+	for _, name := range featureNames {
+		setting := "FEATURE_" + strings.ToUpper(name)
+		enabled := true
+		if value := getenv(setting); value != "" {
+			var err error
+			if enabled, err = strconv.ParseBool(value); err != nil {
+				configProblem(setting, err)
+				enabled = true
+			}
+		}
+		parsed[name] = enabled
+	}
+	return parsed
+}
+
+// featureEnabled whether the feature is switched on
+func featureEnabled(name string) bool {
+	enabled, ok := features[name]
+	return !ok || enabled
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// detectFlapping checks how often the alarm has changed state within the flap window.  The first notification once
+// an alarm starts flapping is replaced with a summary and every one after that is suppressed until it settles down.
+func detectFlapping(ctx context.Context, n *notification) error {
+	transitions, err := stateStore.Transitions(ctx, n.cloudWatchAlarmEvent.AlarmARN, time.Now().Add(-flapWindow))
+	if err != nil {
+		return err
+	}
+
+	if len(transitions) <= flapThreshold {
+		return nil
+	}
+
+	n.flapping = true
+	if n.previousState != nil && n.previousState.Flapping {
+		n.suppressed = "flapping"
+		return nil
+	}
+
+	n.slackAttachment = slack.Attachment{
+		Color: "warning",
+		Title: fmt.Sprintf(":repeat: %s is flapping", n.cloudWatchAlarmEvent.AlarmName),
+		Text: fmt.Sprintf("%d state changes in the last %s, now %s. Notifications for it are suppressed until it settles. <%s|History>",
+			len(transitions), flapWindow, n.cloudWatchAlarmEvent.NewStateValue, consoleURL(n.cloudWatchAlarmEvent)),
+		Footer:     n.slackAttachment.Footer,
+		FooterIcon: n.slackAttachment.FooterIcon,
+		Ts:         n.slackAttachment.Ts,
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// healthResourcesLimit how much of the affected resources list is shown, some events list hundreds
+const healthResourcesLimit = 1000
+
+// healthCategoryColors attachment color for each AWS Health event type category
+var healthCategoryColors = map[string]string{
+	"issue":               "danger",
+	"scheduledChange":     "warning",
+	"accountNotification": "#439FE0",
+}
+
+// HealthEventDetail the detail of an AWS Health Event
+type HealthEventDetail struct {
+	EventARN          string `json:"eventArn"`
+	Service           string `json:"service"`
+	EventTypeCode     string `json:"eventTypeCode"`
+	EventTypeCategory string `json:"eventTypeCategory"`
+	StatusCode        string `json:"statusCode"`
+	StartTime         string `json:"startTime"`
+	EndTime           string `json:"endTime"`
+	EventDescription  []struct {
+		Language          string `json:"language"`
+		LatestDescription string `json:"latestDescription"`
+	} `json:"eventDescription"`
+	AffectedEntities []struct {
+		EntityValue string `json:"entityValue"`
+	} `json:"affectedEntities"`
+}
+
+// healthMessage renders an AWS Health Event delivered from EventBridge through SNS
+func healthMessage(sns events.SNSEntity) (*OtherMessage, error) {
+	event := events.CloudWatchEvent{}
+	if err := json.Unmarshal([]byte(sns.Message), &event); err != nil {
+		return nil, err
+	}
+	detail := HealthEventDetail{}
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		return nil, err
+	}
+
+	description := ""
+	for _, d := range detail.EventDescription {
+		description = d.LatestDescription
+		if d.Language == "en_US" {
+			break
+		}
+	}
+	text, _ := truncate(description, slackTextLimit)
+
+	affected := []string{}
+	for _, entity := range detail.AffectedEntities {
+		affected = append(affected, entity.EntityValue)
+	}
+	resources, _ := truncate(strings.Join(affected, "\n"), healthResourcesLimit)
+	if resources == "" {
+		resources = "None reported"
+	}
+
+	color, ok := healthCategoryColors[detail.EventTypeCategory]
+	if !ok {
+		color = "warning"
+	}
+	link := "https://health.aws.amazon.com/health/home#/account/event-log?eventID=" + url.QueryEscape(detail.EventARN)
+
+	return &OtherMessage{Attachment: slack.Attachment{
+		Color: color,
+		Title: fmt.Sprintf(":hospital: AWS Health %s: %s", detail.Service, detail.EventTypeCode),
+		Text:  fmt.Sprintf("%s\n<%s|Health Dashboard>", text, link),
+		Ts:    time.Now().Unix(),
+		AttachmentField: []slack.AttachmentField{
+			{Title: "Service", Value: detail.Service, Short: true},
+			{Title: "Category", Value: detail.EventTypeCategory, Short: true},
+			{Title: "AccountID", Value: event.AccountID, Short: true},
+			{Title: "Region", Value: event.Region, Short: true},
+			{Title: "Start Time", Value: detail.StartTime, Short: true},
+			{Title: "Status", Value: detail.StatusCode, Short: true},
+			{Title: "Affected Resources", Value: resources, Short: false},
+		},
+	}}, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// heartbeat sends a synthetic alarm through decoding, rendering and the Slack client to HEARTBEAT_CHANNEL, emitting
+// a Heartbeats metric when it gets through and HeartbeatFailures when it doesn't.  Alarming on Heartbeats going
+// missing catches a broken webhook or bad config before a real alarm is lost to it.
+func heartbeat(ctx context.Context) error {
+	if heartbeatChannel == "" {
+		return fmt.Errorf("heartbeat requires HEARTBEAT_CHANNEL to be configured")
+	}
+
+	now := time.Now().UTC()
+	message, err := json.Marshal(CloudWatchAlarmEvent{
+		AlarmName:        "heartbeat",
+		AlarmDescription: "Synthetic alarm sent by the notifier to check it can still deliver",
+		NewStateValue:    "ALARM",
+		NewStateReason:   "Heartbeat at " + now.Format(time.RFC3339),
+		StateChangeTime:  now.Format("2006-01-02T15:04:05.000-0700"),
+		Region:           os.Getenv("AWS_REGION"),
+		OldStateValue:    "OK",
+	})
+	if err != nil {
+		return err
+	}
+	sns := events.SNSEntity{
+		MessageID: "heartbeat-" + now.Format("20060102T150405"),
+		Subject:   `ALARM: "heartbeat"`,
+		Message:   string(message),
+	}
+
+	cloudWatchAlarmEvent, ok := parseAlarm(sns.Message)
+	if !ok {
+		err = fmt.Errorf("heartbeat alarm didn't parse")
+	} else {
+		n := newNotification(ctx, sns, normalizeAlarm(cloudWatchAlarmEvent))
+		sendCtx, cancel := sendBudget(ctx)
+		defer cancel()
+		err = postAttachments(sendCtx, heartbeatChannel, []slack.Attachment{n.slackAttachment})
+	}
+
+	if err != nil {
+		emitMetric("HeartbeatFailures", "Count", 1, nil)
+		return fmt.Errorf("heartbeat failed: %s", err)
+	}
+	emitMetric("Heartbeats", "Count", 1, nil)
+	slog.InfoContext(ctx, "Heartbeat delivered", "channel", heartbeatChannel)
+	return nil
+}
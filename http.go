@@ -0,0 +1,141 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Slack requests older than this are rejected to prevent replays
+const slackRequestMaxAge = 5 * time.Minute
+
+// SlackCommandResponse the immediate response to a slash command
+type SlackCommandResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// HandleHTTP function that handles requests from Slack through API Gateway or a function URL
+func HandleHTTP(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	body := request.Body
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return httpResponse(http.StatusBadRequest, err.Error()), nil
+		}
+		body = string(decoded)
+	}
+
+	if err := verifySlackRequest(request.Headers, body); err != nil {
+		slog.WarnContext(ctx, "Rejected Slack request", "error", err)
+		return httpResponse(http.StatusUnauthorized, "invalid slack signature"), nil
+	}
+
+	// The Events API posts JSON where slash commands and interactions post forms
+	if strings.HasPrefix(strings.TrimSpace(body), "{") {
+		response, err := handleEvent(ctx, body)
+		if err != nil {
+			slog.ErrorContext(ctx, "Handling the Slack event failed", "error", err)
+			return httpResponse(http.StatusInternalServerError, err.Error()), nil
+		}
+		return httpResponse(http.StatusOK, response), nil
+	}
+
+	form, err := url.ParseQuery(body)
+	if err != nil {
+		return httpResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	if command := form.Get("command"); command != "" {
+		response := handleCommand(ctx, command, form)
+		payload, err := json.Marshal(response)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       string(payload),
+		}, nil
+	}
+
+	if payload := form.Get("payload"); payload != "" {
+		if err := handleInteraction(ctx, payload); err != nil {
+			slog.ErrorContext(ctx, "Handling the Slack interaction failed", "error", err)
+			return httpResponse(http.StatusInternalServerError, err.Error()), nil
+		}
+		return httpResponse(http.StatusOK, ""), nil
+	}
+	return httpResponse(http.StatusBadRequest, "unrecognized request"), nil
+}
+
+// verifySlackRequest checks the request was signed by Slack with the signing secret
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func verifySlackRequest(headers map[string]string, body string) error {
+	secret := currentSlackSigningSecret()
+	if secret == "" {
+		return fmt.Errorf("SLACK_SIGNING_SECRET is required to accept requests from slack")
+	}
+
+	timestamp := header(headers, "X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid slack request timestamp %q", timestamp)
+	}
+	if math.Abs(time.Since(time.Unix(seconds, 0)).Seconds()) > slackRequestMaxAge.Seconds() {
+		return fmt.Errorf("stale slack request timestamp %q", timestamp)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header(headers, "X-Slack-Signature"))) {
+		return fmt.Errorf("slack signature mismatch")
+	}
+	return nil
+}
+
+// header looks up the header case insensitively since function URLs lower case them and API Gateway doesn't
+func header(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+func httpResponse(statusCode int, body string) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers:    map[string]string{"Content-Type": "text/plain"},
+		Body:       body,
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifySlackRequest(t *testing.T) {
+	const body = "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&command=%2Fsilence&text=rds-*+1h"
+	sign := func(secret string, timestamp string, body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+		return "v0=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	ahead := strconv.FormatInt(time.Now().Add(10*time.Minute).Unix(), 10)
+	tests := []struct {
+		name    string
+		secret  string
+		headers map[string]string
+		body    string
+		ok      bool
+	}{
+		{"signed", "signing-secret", map[string]string{"X-Slack-Request-Timestamp": now, "X-Slack-Signature": sign("signing-secret", now, body)}, body, true},
+		{"lower case headers", "signing-secret", map[string]string{"x-slack-request-timestamp": now, "x-slack-signature": sign("signing-secret", now, body)}, body, true},
+		{"no signing secret", "", map[string]string{"X-Slack-Request-Timestamp": now, "X-Slack-Signature": sign("", now, body)}, body, false},
+		{"another secret", "signing-secret", map[string]string{"X-Slack-Request-Timestamp": now, "X-Slack-Signature": sign("other-secret", now, body)}, body, false},
+		{"tampered body", "signing-secret", map[string]string{"X-Slack-Request-Timestamp": now, "X-Slack-Signature": sign("signing-secret", now, body)}, body + "&user_name=mallory", false},
+		{"unsigned", "signing-secret", map[string]string{"X-Slack-Request-Timestamp": now}, body, false},
+		{"no timestamp", "signing-secret", map[string]string{"X-Slack-Signature": sign("signing-secret", "", body)}, body, false},
+		{"stale", "signing-secret", map[string]string{"X-Slack-Request-Timestamp": stale, "X-Slack-Signature": sign("signing-secret", stale, body)}, body, false},
+		{"from the future", "signing-secret", map[string]string{"X-Slack-Request-Timestamp": ahead, "X-Slack-Signature": sign("signing-secret", ahead, body)}, body, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			slackSigningSecret = test.secret
+			defer func() { slackSigningSecret = "" }()
+			if err := verifySlackRequest(test.headers, test.body); (err == nil) != test.ok {
+				t.Errorf("verifySlackRequest() = %v, want ok %t", err, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+// Block and action IDs of the interactive elements on alarm messages
+const (
+	alarmActionsBlockID = "alarm_actions"
+	acknowledgeActionID = "acknowledge"
+	silenceActionID     = "silence"
+	runbookActionID     = "runbook"
+)
+
+// silenceDuration how long the Silence button suppresses the alarm for
+const silenceDuration = time.Hour
+
+// SlackInteraction the block_actions payload Slack sends when a button is clicked
+type SlackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+	Message struct {
+		Ts          string                   `json:"ts"`
+		Text        string                   `json:"text"`
+		Blocks      []map[string]interface{} `json:"blocks"`
+		Attachments []json.RawMessage        `json:"attachments"`
+	} `json:"message"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// alarmActions the actions block with the buttons on an ALARM message, the values carry the alarm ARN.  The runbook
+// button is a link, only there when the alarm has a web runbook.
+func alarmActions(n *notification) map[string]interface{} {
+	elements := []map[string]interface{}{
+		{
+			"type":      "button",
+			"action_id": acknowledgeActionID,
+			"text":      map[string]string{"type": "plain_text", "text": "Acknowledge"},
+			"style":     "primary",
+			"value":     n.cloudWatchAlarmEvent.AlarmARN,
+		},
+		{
+			"type":      "button",
+			"action_id": silenceActionID,
+			"text":      map[string]string{"type": "plain_text", "text": "Silence 1h"},
+			"value":     n.cloudWatchAlarmEvent.AlarmARN,
+		},
+	}
+	// Slack rejects the whole message over a button linking anywhere but http(s), like an s3:// runbook
+	if runbook, err := url.Parse(n.runbook); err == nil && (runbook.Scheme == "http" || runbook.Scheme == "https") && runbook.Host != "" {
+		elements = append(elements, map[string]interface{}{
+			"type":      "button",
+			"action_id": runbookActionID,
+			"text":      map[string]string{"type": "plain_text", "text": "View Runbook"},
+			"url":       n.runbook,
+		})
+	}
+	return map[string]interface{}{
+		"type":     "actions",
+		"block_id": alarmActionsBlockID,
+		"elements": elements,
+	}
+}
+
+// handleInteraction handles a button click on an alarm message
+func handleInteraction(ctx context.Context, payload string) error {
+	interaction := SlackInteraction{}
+	if err := json.Unmarshal([]byte(payload), &interaction); err != nil {
+		return err
+	}
+	if interaction.Type != "block_actions" {
+		return nil
+	}
+
+	for _, action := range interaction.Actions {
+		switch action.ActionID {
+		case acknowledgeActionID:
+			if err := acknowledge(ctx, interaction, action.Value); err != nil {
+				return err
+			}
+		case silenceActionID:
+			if err := silence(ctx, interaction, action.Value); err != nil {
+				return err
+			}
+		case detailsActionID:
+			if err := postDetails(ctx, interaction, action.Value); err != nil {
+				return err
+			}
+		case runbookActionID:
+			// Slack opens the link itself, the callback is only telling us it was clicked
+		default:
+			slog.WarnContext(ctx, "Unknown action", "action_id", action.ActionID)
+		}
+	}
+	return nil
+}
+
+// acknowledge records who acknowledged the alarm and swaps the message's buttons for who acked it and when
+func acknowledge(ctx context.Context, interaction SlackInteraction, alarmARN string) error {
+	if stateStore == nil {
+		return fmt.Errorf("acknowledging requires STATE_TABLE to be configured")
+	}
+
+	now := time.Now()
+	alarmState, err := stateStore.GetAlarmState(ctx, alarmARN)
+	if err != nil {
+		return err
+	}
+	if alarmState == nil {
+		alarmState = &AlarmState{AlarmARN: alarmARN}
+	}
+	alarmState.AcknowledgedBy = interaction.User.ID
+	alarmState.AcknowledgedAt = now
+	if err := stateStore.PutAlarmState(ctx, *alarmState); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Acknowledged", "alarm_arn", alarmARN, "user", interaction.User.Username)
+
+	acked := map[string]interface{}{
+		"type": "context",
+		"elements": []map[string]string{
+			{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf(":eyes: acked by <@%s> at <!date^%d^{time}|%s>", interaction.User.ID, now.Unix(), now.UTC().Format("15:04 MST")),
+			},
+		},
+	}
+	return replaceActions(ctx, interaction, acked)
+}
+
+// silence suppresses the alarm for silenceDuration and swaps the message's buttons for who silenced it and until when
+func silence(ctx context.Context, interaction SlackInteraction, alarmARN string) error {
+	if stateStore == nil {
+		return fmt.Errorf("silencing requires STATE_TABLE to be configured")
+	}
+	// A suppression without an alarm would match every alarm
+	if alarmARN == "" {
+		return fmt.Errorf("silence button in %s has no alarm", interaction.Channel.ID)
+	}
+
+	now := time.Now()
+	until := now.Add(silenceDuration)
+	err := stateStore.PutSuppression(ctx, Suppression{
+		// Clicking again on the same message extends the one suppression rather than adding another
+		ID:       fmt.Sprintf("silence-%s-%s", interaction.Channel.ID, interaction.Message.Ts),
+		AlarmARN: alarmARN,
+		Start:    now,
+		End:      until,
+		Reason:   fmt.Sprintf("silenced by <@%s>", interaction.User.ID),
+	})
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Silenced", "alarm_arn", alarmARN, "user", interaction.User.Username, "duration", silenceDuration.String())
+
+	silenced := map[string]interface{}{
+		"type": "context",
+		"elements": []map[string]string{
+			{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf(":mute: silenced by <@%s> until <!date^%d^{time}|%s>", interaction.User.ID, until.Unix(), until.UTC().Format("15:04 MST")),
+			},
+		},
+	}
+	return replaceActions(ctx, interaction, silenced)
+}
+
+// replaceActions updates the message the interaction came from, replacing its actions block
+func replaceActions(ctx context.Context, interaction SlackInteraction, block map[string]interface{}) error {
+	return replaceBlock(ctx, interaction, alarmActionsBlockID, block)
+}
+
+// replaceBlock updates the message the interaction came from, replacing the block with the ID
+func replaceBlock(ctx context.Context, interaction SlackInteraction, blockID string, block map[string]interface{}) error {
+	blocks := []map[string]interface{}{}
+	for _, existing := range interaction.Message.Blocks {
+		if existing["block_id"] == blockID {
+			existing = block
+		}
+		blocks = append(blocks, existing)
+	}
+
+	response := SlackAPIResponse{}
+	err := slackAPI(ctx, "chat.update", SlackMessageUpdate{
+		Channel:     interaction.Channel.ID,
+		Ts:          interaction.Message.Ts,
+		Text:        interaction.Message.Text,
+		Blocks:      blocks,
+		Attachments: interaction.Message.Attachments,
+	}, &response)
+	if err != nil {
+		return err
+	}
+	if !response.OK {
+		return fmt.Errorf("slack chat.update failed: %s", response.Error)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// The kill switch is cached briefly so flipping it takes effect within a minute without a lookup per notification
+const killSwitchTTL = time.Minute
+
+var (
+	killSwitchEnabled bool
+	killSwitchFetched time.Time
+	killSwitchMutex   sync.Mutex
+)
+
+// notificationsEnabled whether the KILL_SWITCH_PARAMETER allows sending notifications.  Anything strconv.ParseBool
+// reads as false disables them.  A missing parameter or a failed lookup leaves them enabled, the kill switch is for
+// muting on purpose and shouldn't silence alarms by accident.
+func notificationsEnabled(ctx context.Context) bool {
+	if killSwitchParameter == "" {
+		return true
+	}
+
+	killSwitchMutex.Lock()
+	defer killSwitchMutex.Unlock()
+	if !killSwitchFetched.IsZero() && time.Since(killSwitchFetched) < killSwitchTTL {
+		return killSwitchEnabled
+	}
+
+	killSwitchEnabled = true
+	// Fetched from SSM itself, so flipping it takes effect within killSwitchTTL rather than the extension's cache
+	value, err := getParameter(bypassSecretsExtension(ctx), killSwitchParameter, false)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ssm.ErrCodeParameterNotFound {
+		err = nil
+	} else if err == nil {
+		if enabled, parseErr := strconv.ParseBool(value); parseErr == nil {
+			killSwitchEnabled = enabled
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Checking the kill switch failed", "parameter", killSwitchParameter, "error", err)
+	}
+	killSwitchFetched = time.Now()
+	return killSwitchEnabled
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandleKinesis function that handles alarm events read from a Kinesis stream.  Kinesis checkpoints at the earliest
+// failed record and redelivers everything after it, so only that record is reported; alarms after it that were
+// delivered are skipped on the retry since their transitions are already marked processed.
+func HandleKinesis(ctx context.Context, event events.KinesisEvent) (SQSBatchResponse, error) {
+	response := SQSBatchResponse{BatchItemFailures: []SQSBatchItemFailure{}}
+
+	failed := map[string]bool{}
+	deliveries := []alarmDelivery{}
+	for _, record := range event.Records {
+		sequenceNumber := record.Kinesis.SequenceNumber
+		slog.InfoContext(ctx, "Processing Kinesis record", "sequence_number", sequenceNumber, "event_source_arn", record.EventSourceArn)
+
+		sns := unwrapMessage(sequenceNumber, record.Kinesis.Data)
+		if sns.Type != snsNotification {
+			continue
+		}
+
+		if !firstDelivery(ctx, sns) {
+			continue
+		}
+		cloudWatchAlarmEvent, ok := parseAlarm(sns.Message)
+		if !ok {
+			if other := renderMessage(ctx, sns); other != nil {
+				if err := postOtherMessages(ctx, []OtherMessage{*other}); err != nil {
+					forgetMessage(ctx, sns.MessageID)
+					failed[sequenceNumber] = true
+				}
+			}
+			continue
+		}
+		deliveries = append(deliveries, alarmDelivery{id: sequenceNumber, sns: sns, cloudWatchAlarmEvent: cloudWatchAlarmEvent})
+	}
+
+	for _, n := range notifyAlarms(ctx, deliveries) {
+		if n.failed == nil {
+			continue
+		}
+		forgetDelivery(ctx, n)
+		failed[n.deliveryID] = true
+	}
+
+	for _, record := range event.Records {
+		if failed[record.Kinesis.SequenceNumber] {
+			response.BatchItemFailures = append(response.BatchItemFailures, SQSBatchItemFailure{ItemIdentifier: record.Kinesis.SequenceNumber})
+			break
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// decryptedEnv the plaintext of the env vars named by KMS_ENCRYPTED, which take their place.  It's only written at cold
+// start, before anything reads it concurrently.
+var decryptedEnv = map[string]string{}
+
+// decryptEnv decrypts the env vars named by KMS_ENCRYPTED, whose values are base64 KMS ciphertext like the Lambda
+// console's encryption helpers produce.  Those are encrypted with the function's name as the encryption context, so
+// that's tried first and then no context for values encrypted with the CLI.  A value that can't be decrypted is a
+// config problem.
+func decryptEnv(ctx context.Context) {
+	for _, name := range splitList(os.Getenv("KMS_ENCRYPTED")) {
+		ciphertext, ok := os.LookupEnv(name)
+		if !ok {
+			configProblem("KMS_ENCRYPTED", fmt.Errorf("%s isn't set", name))
+			continue
+		}
+		plaintext, err := kmsDecrypt(ctx, ciphertext)
+		if err != nil {
+			configProblem(name, fmt.Errorf("decrypting with KMS: %s", err))
+			continue
+		}
+		decryptedEnv[name] = plaintext
+	}
+}
+
+// kmsDecrypt the plaintext of the base64 ciphertext
+func kmsDecrypt(ctx context.Context, ciphertext string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	client, err := kmsService()
+	if err != nil {
+		return "", err
+	}
+
+	input := &kms.DecryptInput{CiphertextBlob: blob}
+	if function := os.Getenv("AWS_LAMBDA_FUNCTION_NAME"); function != "" {
+		input.EncryptionContext = aws.StringMap(map[string]string{"LambdaFunctionName": function})
+	}
+	output, err := client.DecryptWithContext(ctx, input)
+	if err != nil && input.EncryptionContext != nil {
+		input.EncryptionContext = nil
+		output, err = client.DecryptWithContext(ctx, input)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(output.Plaintext), nil
+}
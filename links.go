@@ -0,0 +1,53 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Alarms link their runbook from the description, e.g. "runbook: https://wiki.example.com/high-cpu"
+var runbookMarker = regexp.MustCompile(`(?i)runbook\s*[:=]\s*(\S+)`)
+
+// alarmRegion the region code (e.g. us-east-1) from the alarm ARN.  The Region on the event is the display name.
+func alarmRegion(alarmARN string) string {
+	parts := strings.Split(alarmARN, ":")
+	if len(parts) < 4 {
+		return ""
+	}
+	return parts[3]
+}
+
+// consoleURL link to the alarm in the CloudWatch console
+func consoleURL(cloudWatchAlarmEvent CloudWatchAlarmEvent) string {
+	source := cloudWatchAlarmEvent.Source()
+	return fmt.Sprintf("https://%s/cloudwatch/home?region=%s#alarmsV2:alarm/%s", consoleHost(source.Partition), source.Region, url.PathEscape(cloudWatchAlarmEvent.AlarmName))
+}
+
+// alarmListURL link to every alarm in ALARM in the region in the CloudWatch console
+func alarmListURL(region string) string {
+	return fmt.Sprintf("https://%s/cloudwatch/home?region=%s#alarmsV2:?~(alarmStateFilter~'ALARM)", consoleHost(regionPartition(region)), region)
+}
+
+// runbookURL the runbook linked from the alarm description, if any
+func runbookURL(cloudWatchAlarmEvent CloudWatchAlarmEvent) string {
+	if match := runbookMarker.FindStringSubmatch(cloudWatchAlarmEvent.AlarmDescription); match != nil {
+		return match[1]
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+type logAttrsContextKey struct{}
+
+// requestID the AWS request ID of the invocation being handled.  A container only handles one invocation at a time,
+// so lines logged without a context are attributed to it too.
+var requestID atomic.Value
+
+// contextHandler adds the request ID, the alarms deliveries are attributed to and any attributes added with
+// withLogAttrs to every record, so one alarm can be followed through the logs with Logs Insights
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler
+func (handler contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id, _ := requestID.Load().(string); id != "" {
+		record.AddAttrs(slog.String("aws_request_id", id))
+	}
+	if alarmARNs, ok := ctx.Value(alarmsContextKey{}).([]string); ok && len(alarmARNs) != 0 {
+		record.AddAttrs(slog.Any("alarm_arns", alarmARNs))
+	}
+	if attrs, ok := ctx.Value(logAttrsContextKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler
+func (handler contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{handler.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (handler contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{handler.Handler.WithGroup(name)}
+}
+
+// withLogAttrs adds the attributes, e.g. "message_id", id, to everything logged with the context
+func withLogAttrs(ctx context.Context, args ...any) context.Context {
+	attrs, _ := ctx.Value(logAttrsContextKey{}).([]slog.Attr)
+	record := slog.Record{}
+	record.Add(args...)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return context.WithValue(ctx, logAttrsContextKey{}, attrs)
+}
+
+// startInvocation attributes everything logged from here on to the invocation
+func startInvocation(ctx context.Context) {
+	id := ""
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		id = lc.AwsRequestID
+	}
+	requestID.Store(id)
+}
+
+// newLoggers JSON logging at LOG_LEVEL through the default slog logger
+func newLoggers() {
+	level := slog.LevelInfo
+	if value := getenv("LOG_LEVEL"); value != "" {
+		if err := level.UnmarshalText([]byte(strings.ToUpper(value))); err != nil {
+			level = slog.LevelInfo
+		}
+	}
+
+	handler := contextHandler{slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{AddSource: true, Level: level})}
+	slog.SetDefault(slog.New(handler))
+}
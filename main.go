@@ -18,33 +18,103 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"testing"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/jmoney8080/go-gadget-slack"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
-	// Info Logger
-	Info *log.Logger
-	// Warning Logger
-	Warning *log.Logger
-	// Error Logger
-	Error *log.Logger
-
-	slackClient               *slack.Client
-	slackAttachmentsChunkSize int
-	slackMonitorChannel       string
+	httpClient                  http.Client
+	slackWebhook                string
+	slackBotToken               string
+	slackSigningSecret          string
+	slackResolvedReaction       string
+	slackBlocks                 bool
+	slackSnoozeReaction         string
+	slackSnoozeDuration         time.Duration
+	slackAttachmentsChunkSize   int
+	slackMonitorChannel         string
+	slackAdminChannel           string
+	financeChannel              string
+	trustedAdvisorChannel       string
+	securityChannel             string
+	heartbeatChannel            string
+	snsAutoConfirm              bool
+	backupNotifyAll             bool
+	slackSparkline              bool
+	slackSNSFields              bool
+	flapThreshold               int
+	flapWindow                  time.Duration
+	stormThreshold              int
+	stormWindow                 time.Duration
+	escalationSLA               time.Duration
+	escalationMentions          string
+	escalationClient            *webhookClient
+	renotifyInterval            time.Duration
+	correlationTag              string
+	correlationWindow           time.Duration
+	teamsWebhook                string
+	slackFailoverAfter          int
+	slackFailoverCooldown       time.Duration
+	destinationTimeout          time.Duration
+	slackTimeout                time.Duration
+	teamsTimeout                time.Duration
+	dispatchBudget              time.Duration
+	configRefresh               time.Duration
+	appConfigPollInterval       time.Duration
+	recordConcurrency           int
+	destinations                []Destination
+	slackWorkspaces             map[string]SlackWorkspace
+	severityOverrides           map[Severity]SeverityOverride
+	accountOverrides            map[string]AccountOverride
+	features                    map[string]bool
+	routes                      []Route
+	templates                   map[string]*Template
+	secretsRefresh              time.Duration
+	deadlineMargin              time.Duration
+	quietAuditChannel           string
+	okSuppressionPatterns       []string
+	okSuppressionTag            string
+	suppressionTags             []string
+	alarmDependencies           map[string][]string
+	insufficientDataPolicy      string
+	insufficientDataDelayPeriod time.Duration
+	notifyAfter                 int
+	actionsDisabledPolicy       string
+	quietHours                  *QuietHours
+	runbookSnippets             bool
+	detailsBucket               string
+	detailsURL                  string
+	detailsLinkExpiry           time.Duration
+	killSwitchParameter         string
+	suppressionsParameter       string
+	deadLetterQueue             string
+	quarantineChannel           string
+	quarantineBucket            string
+	quarantinePrefix            string
+	propagateErrors             bool
+	passthrough                 string
+	metricsNamespace            string
+	emfMetrics                  bool
+	tracing                     string
+	defaultSeverity             Severity
+
+	stateStore StateStore
 )
 
 // CloudWatchAlarmEvent the cloudwatch event on the SNS event
 type CloudWatchAlarmEvent struct {
 	AlarmName        string                      `json:"AlarmName"`
+	AlarmARN         string                      `json:"AlarmArn"`
 	AlarmDescription string                      `json:"AlarmDescription"`
 	AWSAccountID     string                      `json:"AWSAccountId"`
 	NewStateValue    string                      `json:"NewStateValue"`
@@ -57,116 +127,624 @@ type CloudWatchAlarmEvent struct {
 
 // CloudWatchAlarmEventTrigger trigger hash from the CloudWatchAlarm Event
 type CloudWatchAlarmEventTrigger struct {
-	Period             int     `json:"Period"`
-	EvaluationPeriods  int     `json:"EvaluationPeriods"`
-	ComparisonOperator string  `json:"ComparisonOperator"`
-	Threshold          float32 `json:"Threshold"`
+	MetricName         string                          `json:"MetricName"`
+	Namespace          string                          `json:"Namespace"`
+	Statistic          string                          `json:"Statistic"`
+	ExtendedStatistic  string                          `json:"ExtendedStatistic"`
+	Dimensions         []CloudWatchAlarmEventDimension `json:"Dimensions"`
+	Period             int                             `json:"Period"`
+	EvaluationPeriods  int                             `json:"EvaluationPeriods"`
+	ComparisonOperator string                          `json:"ComparisonOperator"`
+	Threshold          float32                         `json:"Threshold"`
+}
+
+// CloudWatchAlarmEventDimension metric dimension from the CloudWatchAlarm Event trigger
+type CloudWatchAlarmEventDimension struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 func init() {
+	newLoggers()
+	// The validate command only needs the settings it's given, and tests only the functions they call
+	if validating() || testing.Testing() {
+		return
+	}
+	decryptEnv(context.Background())
+	if _, err := loadConfigFile(context.Background()); err != nil {
+		slog.Warn("Loading the config file failed", "error", err)
+	}
+	if _, err := loadConfigParameters(context.Background()); err != nil {
+		slog.Warn("Loading CONFIG_PARAMETER_PATH failed", "error", err)
+	}
+	if _, err := loadAppConfig(context.Background()); err != nil {
+		slog.Warn("Loading AppConfig failed", "error", err)
+	}
+	applyPreset()
+	// Again now that LOG_LEVEL can come from any of the layers, the first loggers are only for loading them
+	newLoggers()
+	tracing = strings.ToLower(getenv("TRACING"))
+	if envBool("XRAY_TRACING") && tracing == "" {
+		tracing = tracingXRay
+	}
+	if tracing == tracingOTel {
+		if err := newOpenTelemetry(context.Background()); err != nil {
+			configProblem("TRACING", err)
+			tracing = ""
+		}
+	}
 
-	Info = log.New(os.Stdout,
-		"[INFO]: ",
-		log.Ldate|log.Ltime|log.Lshortfile)
+	retryJitter, err := strconv.ParseFloat(getenv("RETRY_JITTER"), 64)
+	if err != nil || retryJitter < 0 || retryJitter > 1 {
+		configProblem("RETRY_JITTER", fmt.Errorf("%q isn't a fraction between 0 and 1", getenv("RETRY_JITTER")))
+		retryJitter, _ = strconv.ParseFloat(configDefaults["RETRY_JITTER"], 64)
+	}
+	// HTTP_TIMEOUT limits each attempt, the retries all together are limited by the context of the send, so
+	// SLACK_TIMEOUT, TEAMS_TIMEOUT and DESTINATION_TIMEOUT can be longer than it
+	attemptTimeout := envDuration("HTTP_TIMEOUT")
+	httpClient = http.Client{
+		Transport: newCircuitBreaker(retryTransport{
+			next:     traceTransport(newTransport(envBool("HTTP2"), attemptTimeout)),
+			attempts: envInt("RETRY_ATTEMPTS", 1),
+			backoff:  envDuration("RETRY_BACKOFF"),
+			jitter:   retryJitter,
+			timeout:  attemptTimeout,
+		}, envInt("CIRCUIT_BREAKER_THRESHOLD", 0), envDuration("CIRCUIT_BREAKER_COOLDOWN")),
+	}
+	configure()
 
-	Warning = log.New(os.Stdout,
-		"[WARNING]: ",
-		log.Ldate|log.Ltime|log.Lshortfile)
+	secretsRefresh = envDuration("SECRETS_REFRESH")
+	loadSecrets(context.Background())
 
-	Error = log.New(os.Stderr,
-		"[ERROR]: ",
-		log.Ldate|log.Ltime|log.Lshortfile)
+	if stateTable := getenv("STATE_TABLE"); stateTable != "" {
+		// Without a client there's no state, so the notifier carries on as it would without STATE_TABLE
+		if client, err := dynamoDBService(); err != nil {
+			configProblem("STATE_TABLE", err)
+		} else {
+			stateStore = NewDynamoDBStateStore(client, stateTable, envDuration("STATE_RETENTION"))
+		}
+	}
+
+	// Configuration that's wrong fails the cold start, unless CONFIG_VALIDATION is warn
+	if err := validateConfig(); err != nil {
+		if strings.ToLower(getenv("CONFIG_VALIDATION")) == "warn" {
+			slog.Warn("Invalid configuration", "error", err)
+		} else {
+			slog.Error("Invalid configuration", "error", err)
+			os.Exit(1)
+		}
+	}
+}
 
-	slackClient = slack.New(http.Client{Timeout: 10 * time.Second}, os.Getenv("SLACK_WEBHOOK"))
+// configure reads the settings that can change while the function is warm, which is run again whenever the config
+// file or the configuration from AppConfig changes
+func configure() {
+	slackFailoverAfter = envInt("SLACK_FAILOVER_AFTER", 1)
+	slackFailoverCooldown = envDuration("SLACK_FAILOVER_COOLDOWN")
+	slackResolvedReaction = getenv("SLACK_RESOLVED_REACTION")
+	slackBlocks = envBool("SLACK_BLOCKS")
+	slackSnoozeReaction = getenv("SLACK_SNOOZE_REACTION")
+	slackSnoozeDuration = envDuration("SLACK_SNOOZE_DURATION")
 	slackAttachmentsChunkSize = 100
-	slackMonitorChannel = os.Getenv("SLACK_MONITOR_CHANNEL")
+	slackMonitorChannel = getenv("SLACK_MONITOR_CHANNEL")
+	slackAdminChannel = slackMonitorChannel
+	if value := getenv("SLACK_ADMIN_CHANNEL"); value != "" {
+		slackAdminChannel = value
+	}
+	financeChannel = getenv("FINANCE_CHANNEL")
+	trustedAdvisorChannel = getenv("TRUSTED_ADVISOR_CHANNEL")
+	securityChannel = getenv("SECURITY_CHANNEL")
+	heartbeatChannel = getenv("HEARTBEAT_CHANNEL")
+	snsAutoConfirm = envBool("SNS_AUTO_CONFIRM")
+	backupNotifyAll = envBool("BACKUP_NOTIFY_ALL")
+	slackSparkline = envBool("SLACK_SPARKLINE")
+	slackSNSFields = envBool("SLACK_SNS_FIELDS")
+	flapThreshold = envInt("FLAP_THRESHOLD", 0)
+	flapWindow = envDuration("FLAP_WINDOW")
+	stormThreshold = envInt("STORM_THRESHOLD", 0)
+	stormWindow = envDuration("STORM_WINDOW")
+	escalationSLA = envDuration("ESCALATION_SLA")
+	escalationMentions = getenv("ESCALATION_MENTIONS")
+	renotifyInterval = envDuration("RENOTIFY_INTERVAL")
+	correlationTag = envString("CORRELATION_TAG")
+	correlationWindow = envDuration("CORRELATION_WINDOW")
+	destinationTimeout = envDuration("DESTINATION_TIMEOUT")
+	// SLACK_TIMEOUT and TEAMS_TIMEOUT default to DESTINATION_TIMEOUT
+	slackTimeout, teamsTimeout = destinationTimeout, destinationTimeout
+	if getenv("SLACK_TIMEOUT") != "" {
+		slackTimeout = envDuration("SLACK_TIMEOUT")
+	}
+	if getenv("TEAMS_TIMEOUT") != "" {
+		teamsTimeout = envDuration("TEAMS_TIMEOUT")
+	}
+	dispatchBudget = envDuration("DISPATCH_BUDGET")
+	configRefresh = envDuration("CONFIG_REFRESH")
+	appConfigPollInterval = envDuration("APPCONFIG_POLL_INTERVAL")
+	recordConcurrency = envInt("RECORD_CONCURRENCY", 1)
+	deadlineMargin = envDuration("DEADLINE_MARGIN")
+	quietAuditChannel = getenv("QUIET_AUDIT_CHANNEL")
+	okSuppressionPatterns = splitList(getenv("OK_SUPPRESSION_PATTERNS"))
+	okSuppressionTag = getenv("OK_SUPPRESSION_TAG")
+	suppressionTags = splitList(getenv("SUPPRESSION_TAGS"))
+	insufficientDataPolicy = strings.ToLower(envString("INSUFFICIENT_DATA_POLICY"))
+	notifyAfter = envInt("NOTIFY_AFTER", 1)
+	actionsDisabledPolicy = strings.ToLower(envString("ACTIONS_DISABLED_POLICY"))
+	insufficientDataDelayPeriod = envDuration("INSUFFICIENT_DATA_DELAY")
+	quietHours = nil
+	if value := getenv("QUIET_HOURS"); value != "" {
+		var err error
+		if quietHours, err = ParseQuietHours(value, envString("QUIET_HOURS_TIMEZONE")); err != nil {
+			configProblem("QUIET_HOURS", err)
+		}
+	}
+	alarmDependencies = nil
+	if value := getenv("ALARM_DEPENDENCIES"); value != "" {
+		if err := json.Unmarshal([]byte(value), &alarmDependencies); err != nil {
+			configProblem("ALARM_DEPENDENCIES", err)
+		}
+	}
+	destinations = nil
+	if value := getenv("DESTINATIONS"); value != "" {
+		parsed, err := parseDestinations(value)
+		if err != nil {
+			configProblem("DESTINATIONS", err)
+		} else {
+			destinations = parsed
+		}
+	}
+	// Workspaces and templates first, routes name them
+	slackWorkspaces = nil
+	if value := getenv("SLACK_WORKSPACES"); value != "" {
+		parsed, err := parseSlackWorkspaces(value)
+		if err != nil {
+			configProblem("SLACK_WORKSPACES", err)
+		} else {
+			slackWorkspaces = parsed
+		}
+	}
+	templates = map[string]*Template{}
+	if value := getenv("TEMPLATES"); value != "" {
+		parsed, err := parseTemplates(value)
+		if err != nil {
+			configProblem("TEMPLATES", err)
+		} else {
+			templates = parsed
+		}
+	}
+	routes = nil
+	if value := getenv("ROUTES"); value != "" {
+		parsed, err := parseRoutes(value)
+		if err != nil {
+			configProblem("ROUTES", err)
+		} else {
+			routes = parsed
+		}
+	}
+	// After templates, workspaces and destinations, overrides name them
+	accountOverrides = nil
+	if value := getenv("ACCOUNTS"); value != "" {
+		parsed, err := parseAccountOverrides(value)
+		if err != nil {
+			configProblem("ACCOUNTS", err)
+		} else {
+			accountOverrides = parsed
+		}
+	}
+	severityOverrides = nil
+	if value := getenv("SEVERITIES"); value != "" {
+		parsed, err := parseSeverityOverrides(value)
+		if err != nil {
+			configProblem("SEVERITIES", err)
+		} else {
+			severityOverrides = parsed
+		}
+	}
+	features = parseFeatures()
+	runbookSnippets = envBool("RUNBOOK_SNIPPETS")
+	detailsBucket = getenv("DETAILS_BUCKET")
+	detailsURL = strings.TrimSuffix(getenv("DETAILS_URL"), "/")
+	detailsLinkExpiry = envDuration("DETAILS_LINK_EXPIRY")
+	if detailsLinkExpiry > detailsLinkMaxExpiry {
+		configProblem("DETAILS_LINK_EXPIRY", fmt.Errorf("%s is longer than a presigned URL can last, %s", detailsLinkExpiry, detailsLinkMaxExpiry))
+		detailsLinkExpiry = detailsLinkMaxExpiry
+	}
+	killSwitchParameter = getenv("KILL_SWITCH_PARAMETER")
+	suppressionsParameter = getenv("SUPPRESSIONS_PARAMETER")
+	deadLetterQueue = getenv("DEAD_LETTER_QUEUE")
+	quarantineChannel = getenv("QUARANTINE_CHANNEL")
+	quarantineBucket = getenv("QUARANTINE_BUCKET")
+	quarantinePrefix = getenv("QUARANTINE_PREFIX")
+	propagateErrors = envBool("PROPAGATE_ERRORS")
+	passthrough = strings.ToLower(envString("PASSTHROUGH"))
+	metricsNamespace = envString("METRICS_NAMESPACE")
+	emfMetrics = envBool("EMF_METRICS")
+
+	var err error
+	if defaultSeverity, err = ParseSeverity(envString("DEFAULT_SEVERITY")); err != nil {
+		configProblem("DEFAULT_SEVERITY", err)
+		defaultSeverity, _ = ParseSeverity(configDefaults["DEFAULT_SEVERITY"])
+	}
+}
+
+// envDuration a duration setting, its default from configDefaults when unset or invalid
+func envDuration(name string) time.Duration {
+	if value := getenv(name); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err == nil {
+			return duration
+		}
+		configProblem(name, err)
+	}
+	duration, _ := time.ParseDuration(configDefaults[name])
+	return duration
+}
+
+// envInt an integer setting of at least min, its default from configDefaults when unset or invalid
+func envInt(name string, min int) int {
+	if value := getenv(name); value != "" {
+		number, err := strconv.Atoi(value)
+		if err == nil && number < min {
+			err = fmt.Errorf("must be at least %d", min)
+		}
+		if err == nil {
+			return number
+		}
+		configProblem(name, err)
+	}
+	number, _ := strconv.Atoi(configDefaults[name])
+	return number
+}
+
+// envBool a boolean setting, its default from configDefaults when unset or invalid
+func envBool(name string) bool {
+	if value := getenv(name); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err == nil {
+			return enabled
+		}
+		configProblem(name, err)
+	}
+	enabled, _ := strconv.ParseBool(configDefaults[name])
+	return enabled
+}
+
+// envString a setting, its default from configDefaults when set empty
+func envString(name string) string {
+	if value := getenv(name); value != "" {
+		return value
+	}
+	return configDefaults[name]
+}
+
+// splitList splits a comma separated env var dropping any empty entries
+func splitList(value string) []string {
+	list := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
 }
 
 func main() {
-	lambda.Start(HandleRequest)
+	if validating() {
+		os.Exit(validateCommand(os.Args[2:]))
+	}
+	lambda.Start(Handle)
 }
 
-// HandleRequest function that the lambda runtime service calls
+// Handle function that the lambda runtime service calls, routing the invocation by the shape of its payload.  SNS
+// events, SQS messages carrying SNS notifications, EventBridge events and alarms invoking the function as their action
+// are notifications, anything with a request context is an HTTP request from Slack and anything with a task is a
+// scheduled task.
+func Handle(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	startInvocation(ctx)
+	refreshConfigFile(ctx)
+	refreshConfigParameters(ctx)
+	refreshAppConfig(ctx)
+	loadSecrets(ctx)
+	ctx, endTrace := traceInvocation(ctx)
+	defer endTrace()
+	invocation := struct {
+		RequestContext json.RawMessage `json:"requestContext"`
+		Task           string          `json:"task"`
+		DetailType     string          `json:"detail-type"`
+		Records        []struct {
+			EventSource string `json:"eventSource"`
+		} `json:"Records"`
+	}{}
+	if err := json.Unmarshal(payload, &invocation); err != nil {
+		quarantine(ctx, "invocation", "", payload, err)
+		return nil, err
+	}
+
+	// The config and test tasks answer with a report rather than only running
+	if invocation.Task == "config" {
+		return effectiveConfig(), nil
+	}
+	if invocation.Task == "test" {
+		return selfTest(ctx)
+	}
+	if invocation.Task != "" {
+		return nil, HandleTask(ctx, ScheduledTask{Task: invocation.Task})
+	}
+
+	// Alarms delivered without an SNS topic are converted by the parser that detects them
+	alarm := events.SNSEntity{Message: string(payload)}
+	if parser, ok := alarmParser(alarm); ok {
+		cloudWatchAlarmEvent, err := parser.Parse(alarm)
+		if err != nil {
+			quarantine(ctx, parser.Name, "", payload, err)
+			return nil, err
+		}
+		return nil, HandleAlarm(ctx, cloudWatchAlarmEvent)
+	}
+
+	if invocation.DetailType != "" {
+		event := events.CloudWatchEvent{}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		return nil, HandleEventBridge(ctx, event)
+	}
+
+	if invocation.RequestContext != nil {
+		request := events.APIGatewayProxyRequest{}
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, err
+		}
+		return HandleHTTP(ctx, request)
+	}
+
+	if len(invocation.Records) != 0 && invocation.Records[0].EventSource == "aws:sqs" {
+		event := events.SQSEvent{}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		return HandleSQS(ctx, event)
+	}
+
+	if len(invocation.Records) != 0 && invocation.Records[0].EventSource == "aws:kinesis" {
+		event := events.KinesisEvent{}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		return HandleKinesis(ctx, event)
+	}
+
+	event := events.SNSEvent{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		quarantine(ctx, "sns", "", payload, err)
+		return nil, err
+	}
+	return nil, HandleRequest(ctx, event)
+}
+
+// HandleRequest function that the lambda runtime service calls.  Records are parsed and enriched as they're picked up
+// by RECORD_CONCURRENCY workers, so a slow lookup for one alarm doesn't hold up the rest, then the batch's alarms are
+// dispatched together so they're still ordered by priority, correlated and counted toward a storm as one batch.  The
+// outcome of every record is logged once they're done, and with PROPAGATE_ERRORS the ones that failed are returned as
+// the error.
 func HandleRequest(ctx context.Context, event events.SNSEvent) error {
-	slackAttachments := []slack.Attachment{}
+	results := newRecordResults()
 
+	// Control messages are acted on before any alarm is, so the whole batch goes out with the reloaded configuration
+	records := []events.SNSEventRecord{}
 	for _, eventRecord := range event.Records {
-		cloudWatchAlarmEvent := CloudWatchAlarmEvent{}
-		json.NewDecoder(strings.NewReader(eventRecord.SNS.Message)).Decode(&cloudWatchAlarmEvent)
-
-		color := "good"
-		if cloudWatchAlarmEvent.NewStateValue == "ALARM" {
-			color = "danger"
-		} else if cloudWatchAlarmEvent.NewStateValue == "INSUFFICIENT_DATA" {
-			color = "warning"
-		}
-
-		slackAttachment := slack.Attachment{
-			Color:      color,
-			Title:      eventRecord.SNS.Subject,
-			Text:       cloudWatchAlarmEvent.NewStateReason,
-			Footer:     os.Getenv("AWS_LAMBDA_FUNCTION_NAME"),
-			FooterIcon: "https://d1d05r7k0qlw4w.cloudfront.net/dist-cbe91c5a8477701757ff6752aae4c6f892018972/img/favicon.ico",
-			Ts:         time.Now().UnixNano() / int64(time.Second),
-			AttachmentField: []slack.AttachmentField{
-				{
-					Title: "AccountID",
-					Value: cloudWatchAlarmEvent.AWSAccountID,
-					Short: true,
-				},
-				{
-					Title: "Region",
-					Value: cloudWatchAlarmEvent.Region,
-					Short: true,
-				},
-				{
-					Title: "Period",
-					Value: fmt.Sprintf("%v", cloudWatchAlarmEvent.Trigger.Period),
-					Short: true,
-				},
-				{
-					Title: "Threshold",
-					Value: fmt.Sprintf("%v", cloudWatchAlarmEvent.Trigger.Threshold),
-					Short: true,
-				},
-				{
-					Title: "Evaluated Periods",
-					Value: fmt.Sprintf("%v", cloudWatchAlarmEvent.Trigger.EvaluationPeriods),
-					Short: true,
-				},
-				{
-					Title: "Comparison Operator",
-					Value: cloudWatchAlarmEvent.Trigger.ComparisonOperator,
-					Short: true,
-				},
-			},
-		}
-		slackAttachments = append(slackAttachments, slackAttachment)
-	}
-
-	if len(slackAttachments) != 0 {
-		// Here we are chunking up the attachments.  Slack only allows 100 attachments in one post. While that'd be insane and absurd to do, it's a known limit
-		// we can easily account for in the code
-		for i := 0; i < len(slackAttachments); i += slackAttachmentsChunkSize {
-			end := i + slackAttachmentsChunkSize
-			if end > len(slackAttachments) {
-				end = len(slackAttachments)
-			}
+		control, ok := controlMessage(eventRecord.SNS)
+		if !ok {
+			records = append(records, eventRecord)
+			continue
+		}
+		if control == controlReload {
+			reloadConfig(ctx)
+		} else {
+			slog.WarnContext(ctx, "Unknown control message", "message_id", eventRecord.SNS.MessageID, "control", control)
+		}
+		results.record(eventRecord.SNS.MessageID, outcomeSkipped, nil)
+	}
+	notifier := newAlarmNotifier(ctx)
 
-			chunkedSlackAttachments := slackAttachments[i:end]
-			payload := slack.Payload{
-				Channel:     slackMonitorChannel,
-				Attachments: chunkedSlackAttachments,
-			}
-			resp, err := (*slackClient).Send(payload)
-			if err != nil {
-				Error.Println(err)
-			} else {
-				Info.Println(resp)
-			}
+	// Each worker fills in its own record's notification, which keeps them in the order the records came in
+	enriched := make([]*notification, len(records))
+	group := errgroup.Group{}
+	group.SetLimit(recordConcurrency)
+	for i, eventRecord := range records {
+		i, eventRecord := i, eventRecord
+		group.Go(func() error {
+			enriched[i] = handleRecord(ctx, eventRecord.SNS, notifier, results)
+			return nil
+		})
+	}
+	group.Wait()
+
+	notifications := []*notification{}
+	for _, n := range enriched {
+		if n != nil {
+			notifications = append(notifications, n)
 		}
-	} else {
-		Warning.Println("No Slack Sent")
+	}
+	dispatch(ctx, notifications)
+	results.recordNotifications(notifications)
+	// The failed alarms are dead lettered, or with PROPAGATE_ERRORS forgotten so they're retried
+	settleFailures(ctx, notifications)
+	results.summarize(ctx)
+
+	if propagateErrors {
+		return results.err()
 	}
 	return nil
 }
+
+// handleRecord processes the SNS message delivered by one record, recording its outcome.  A message that isn't an alarm
+// is posted straight away, an alarm's notification is returned to be dispatched with the rest of the batch, nil when
+// there's nothing to dispatch.
+func handleRecord(ctx context.Context, sns events.SNSEntity, notifier *alarmNotifier, results *recordResults) *notification {
+	slog.InfoContext(ctx, "Processing SNS message", "message_id", sns.MessageID, "topic_arn", sns.TopicArn)
+	// Lambda subscriptions are confirmed by SNS itself so anything but a notification has nothing to notify about
+	if sns.Type != "" && sns.Type != snsNotification {
+		slog.InfoContext(ctx, "Skipping SNS message", "message_id", sns.MessageID, "type", sns.Type)
+		results.record(sns.MessageID, outcomeSkipped, nil)
+		return nil
+	}
+
+	sns = unwrapEnvelope(sns)
+	if !firstDelivery(ctx, sns) {
+		results.record(sns.MessageID, outcomeDuplicate, nil)
+		return nil
+	}
+	cloudWatchAlarmEvent, ok := parseAlarm(sns.Message)
+	if !ok {
+		other := renderMessage(ctx, sns)
+		if other == nil {
+			results.record(sns.MessageID, outcomeDropped, nil)
+			return nil
+		}
+		if err := postOtherMessages(ctx, []OtherMessage{*other}); err != nil {
+			forgetMessage(ctx, sns.MessageID)
+			results.record(sns.MessageID, outcomeFailed, err)
+			return nil
+		}
+		results.record(sns.MessageID, outcomeSent, nil)
+		return nil
+	}
+
+	// Transitions the notifier drops as duplicates don't get a notification, the rest get their outcome once dispatched
+	n := notifier.enrich(ctx, alarmDelivery{sns: sns, cloudWatchAlarmEvent: cloudWatchAlarmEvent})
+	if n == nil {
+		results.record(sns.MessageID, outcomeDuplicate, nil)
+	}
+	return n
+}
+
+// alarmDelivery an alarm event along with the SNS message that delivered it, which is empty when the event didn't come
+// through SNS, and the ID of the record it came in when the event source retries individual records
+type alarmDelivery struct {
+	id                   string
+	sns                  events.SNSEntity
+	cloudWatchAlarmEvent CloudWatchAlarmEvent
+}
+
+// notifyAlarms evaluates and dispatches the notifications for the delivered alarm events, returning the notifications
+// so failures can be reported per record
+func notifyAlarms(ctx context.Context, deliveries []alarmDelivery) []*notification {
+	return newAlarmNotifier(ctx).notify(ctx, deliveries)
+}
+
+// alarmNotifier what's shared by the alarms notified in one invocation, the suppressions in effect and the transitions
+// already seen.  enrich can be called for each record as it's processed, from more than one goroutine.
+type alarmNotifier struct {
+	suppressions []Suppression
+	seen         map[string]bool
+	mutex        sync.Mutex
+}
+
+// newAlarmNotifier a notifier with the suppressions currently in effect
+func newAlarmNotifier(ctx context.Context) *alarmNotifier {
+	suppressions, err := currentSuppressions(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Loading the suppressions failed", "error", err)
+	}
+	return &alarmNotifier{suppressions: suppressions, seen: map[string]bool{}}
+}
+
+// firstSeen whether the transition hasn't been seen yet in this invocation, marking it seen
+func (notifier *alarmNotifier) firstSeen(key string) bool {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+	first := !notifier.seen[key]
+	notifier.seen[key] = true
+	return first
+}
+
+// notify evaluates and dispatches the notifications for the delivered alarm events
+func (notifier *alarmNotifier) notify(ctx context.Context, deliveries []alarmDelivery) []*notification {
+	notifications := []*notification{}
+	for _, delivery := range deliveries {
+		if n := notifier.enrich(ctx, delivery); n != nil {
+			notifications = append(notifications, n)
+		}
+	}
+
+	dispatch(ctx, notifications)
+	return notifications
+}
+
+// enrich evaluates the notification for the delivered alarm event, nil when the transition is a duplicate
+func (notifier *alarmNotifier) enrich(ctx context.Context, delivery alarmDelivery) *notification {
+	cloudWatchAlarmEvent := normalizeAlarm(delivery.cloudWatchAlarmEvent)
+
+	// SNS delivers at least once, and an alarm can reach us through more than one topic, each with its own MessageId,
+	// so the transition itself is what's deduplicated.  Copies in the same batch are caught even without a state store.
+	if cloudWatchAlarmEvent.AlarmARN != "" {
+		key := transitionKey(cloudWatchAlarmEvent)
+		first := notifier.firstSeen(key)
+		if first && stateStore != nil {
+			var err error
+			if first, err = stateStore.MarkProcessed(ctx, key); err != nil {
+				slog.WarnContext(ctx, "Marking the transition processed failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+				first = true
+			}
+		}
+		if !first {
+			slog.InfoContext(ctx, "Dropping duplicate transition", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "state", cloudWatchAlarmEvent.NewStateValue, "state_change_time", cloudWatchAlarmEvent.StateChangeTime)
+			return nil
+		}
+	}
+
+	ctx = withLogAttrs(ctx, "message_id", delivery.sns.MessageID, "alarm_arn", cloudWatchAlarmEvent.AlarmARN)
+	var n *notification
+	trace(ctx, "enrich", func(ctx context.Context) error {
+		n = newNotification(ctx, delivery.sns, cloudWatchAlarmEvent)
+		n.deliveryID, n.messageID = delivery.id, delivery.sns.MessageID
+		applyTemplate(n)
+		evaluate(ctx, n, notifier.suppressions)
+		return nil
+	})
+	return n
+}
+
+// firstDelivery whether this is the first time the SNS message has been delivered, marking it processed.  Lambda
+// retries and SNS redeliveries carry the same MessageId, so they're dropped here before anything is sent.  It's marked
+// before the send so concurrent copies don't both go out, which leaves every way the send can fail having to forget it
+// again.
+func firstDelivery(ctx context.Context, sns events.SNSEntity) bool {
+	if stateStore == nil || sns.MessageID == "" {
+		return true
+	}
+	first, err := stateStore.MarkProcessed(ctx, messageKey(sns.MessageID))
+	if err != nil {
+		slog.WarnContext(ctx, "Marking the SNS message processed failed", "message_id", sns.MessageID, "error", err)
+		return true
+	}
+	if !first {
+		slog.InfoContext(ctx, "Dropping duplicate SNS message", "message_id", sns.MessageID)
+	}
+	return first
+}
+
+// forgetMessage lets the SNS message be processed again when it's retried
+func forgetMessage(ctx context.Context, messageID string) {
+	if stateStore == nil || messageID == "" {
+		return
+	}
+	if err := stateStore.ForgetProcessed(ctx, messageKey(messageID)); err != nil {
+		slog.WarnContext(ctx, "Forgetting the SNS message failed", "message_id", messageID, "error", err)
+	}
+}
+
+// messageKey the processed key of an SNS message, kept apart from transition keys which start with the alarm ARN
+func messageKey(messageID string) string {
+	return "message#" + messageID
+}
+
+// transitionKey identifies the alarm's state change regardless of which topic delivered it.  The change time is
+// normalized so the same instant formatted differently still matches.
+func transitionKey(cloudWatchAlarmEvent CloudWatchAlarmEvent) string {
+	changed := cloudWatchAlarmEvent.StateChangeTime
+	if parsed, err := time.Parse(stateChangeTimeLayout, changed); err == nil {
+		changed = parsed.UTC().Format(transitionKeyLayout)
+	}
+	return cloudWatchAlarmEvent.AlarmARN + "#" + changed
+}
@@ -0,0 +1,315 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// How SNS messages that aren't CloudWatch alarms are posted
+const (
+	passthroughPretty = "pretty"
+	passthroughRaw    = "raw"
+	passthroughDrop   = "drop"
+)
+
+// OtherMessage a rendered SNS message that isn't a CloudWatch alarm along with the channel it goes to, the monitor
+// channel when empty
+type OtherMessage struct {
+	Channel    string
+	Attachment slack.Attachment
+}
+
+// MessageParser recognizes one kind of SNS message that isn't a CloudWatch alarm and renders it.  Parse returns a nil
+// message for ones it recognizes but drops.
+type MessageParser struct {
+	Name   string
+	Detect func(sns events.SNSEntity) bool
+	Parse  func(sns events.SNSEntity) (*OtherMessage, error)
+}
+
+// messageParsers the parsers renderMessage picks from, the first one to detect a message parses it
+var messageParsers = []MessageParser{
+	{Name: "health", Detect: eventSource("aws.health"), Parse: healthMessage},
+	{Name: "budgets", Detect: isBudgetsMessage, Parse: budgetsMessage},
+	{Name: "cloudformation", Detect: isCloudFormationMessage, Parse: cloudFormationMessage},
+	{Name: "rds", Detect: hasFields("Source ID", "Event Message"), Parse: rdsMessage},
+	{Name: "beanstalk", Detect: isBeanstalkMessage, Parse: beanstalkMessage},
+	{Name: "s3", Detect: isS3Message, Parse: s3Message},
+	{Name: "codepipeline", Detect: eventSource("aws.codepipeline", "aws.codebuild"), Parse: codePipelineMessage},
+	{Name: "backup", Detect: isBackupMessage, Parse: backupMessage},
+	{Name: "trustedadvisor", Detect: eventSource("aws.trustedadvisor"), Parse: trustedAdvisorMessage},
+	{Name: "devopsguru", Detect: hasFields("insightId"), Parse: devOpsGuruMessage},
+	{Name: "costanomaly", Detect: hasFields("anomalyId", "monitorArn"), Parse: costAnomalyMessage},
+	{Name: "securityhub", Detect: eventSource("aws.securityhub"), Parse: securityHubMessage},
+}
+
+// AlarmParser recognizes one shape of CloudWatch alarm event and converts it into the one SNS delivers
+type AlarmParser struct {
+	Name   string
+	Detect func(sns events.SNSEntity) bool
+	Parse  func(sns events.SNSEntity) (CloudWatchAlarmEvent, error)
+}
+
+// alarmParsers the parsers alarms are picked out with, whether they come through SNS, EventBridge or as an alarm action
+var alarmParsers = []AlarmParser{
+	{Name: "alarm", Detect: hasFields("AlarmName"), Parse: cloudWatchAlarm},
+	{Name: "eventbridge", Detect: detailType(eventBridgeAlarmStateChange), Parse: eventBridgeAlarm},
+	{Name: "alarmaction", Detect: hasFields("alarmData"), Parse: alarmAction},
+}
+
+// eventSource detects EventBridge events from any of the sources
+func eventSource(sources ...string) func(events.SNSEntity) bool {
+	return func(sns events.SNSEntity) bool {
+		event := struct {
+			Source string `json:"source"`
+		}{}
+		if err := json.Unmarshal([]byte(sns.Message), &event); err != nil {
+			return false
+		}
+		for _, source := range sources {
+			if event.Source == source {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// detailType detects EventBridge events of any of the detail-types
+func detailType(types ...string) func(events.SNSEntity) bool {
+	return func(sns events.SNSEntity) bool {
+		event := struct {
+			DetailType string `json:"detail-type"`
+		}{}
+		if err := json.Unmarshal([]byte(sns.Message), &event); err != nil {
+			return false
+		}
+		for _, detailType := range types {
+			if event.DetailType == detailType {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// hasFields detects JSON messages with all of the fields
+func hasFields(names ...string) func(events.SNSEntity) bool {
+	return func(sns events.SNSEntity) bool {
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal([]byte(sns.Message), &fields); err != nil {
+			return false
+		}
+		for _, name := range names {
+			if _, ok := fields[name]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// alarmParser the first of the alarm parsers to detect the message, reporting whether any did
+func alarmParser(sns events.SNSEntity) (AlarmParser, bool) {
+	for _, parser := range alarmParsers {
+		if parser.Detect(sns) {
+			return parser, true
+		}
+	}
+	return AlarmParser{}, false
+}
+
+// parseAlarm decodes the message as a CloudWatch alarm event with the parser that detects it, reporting whether it was
+// one
+func parseAlarm(message string) (CloudWatchAlarmEvent, bool) {
+	sns := events.SNSEntity{Message: message}
+	parser, ok := alarmParser(sns)
+	if !ok {
+		return CloudWatchAlarmEvent{}, false
+	}
+	cloudWatchAlarmEvent, err := parser.Parse(sns)
+	return cloudWatchAlarmEvent, err == nil
+}
+
+// cloudWatchAlarm decodes the alarm event CloudWatch publishes to SNS
+func cloudWatchAlarm(sns events.SNSEntity) (CloudWatchAlarmEvent, error) {
+	cloudWatchAlarmEvent := CloudWatchAlarmEvent{}
+	if err := json.Unmarshal([]byte(sns.Message), &cloudWatchAlarmEvent); err != nil {
+		return cloudWatchAlarmEvent, err
+	}
+	if cloudWatchAlarmEvent.AlarmName == "" || cloudWatchAlarmEvent.NewStateValue == "" {
+		return cloudWatchAlarmEvent, fmt.Errorf("alarm event is missing its AlarmName or NewStateValue")
+	}
+	return cloudWatchAlarmEvent, nil
+}
+
+// unwrapMessage decodes a message body delivered through SQS or Kinesis into the SNS notification it was, whether it
+// is the SNS envelope, possibly wrapping another topic's envelope, or was delivered raw.  Raw alarms in any of the
+// shapes the alarm parsers detect are converted into the alarm event SNS would have delivered.
+func unwrapMessage(id string, body []byte) events.SNSEntity {
+	sns := events.SNSEntity{}
+	if err := json.Unmarshal(body, &sns); err != nil || sns.Type == "" || sns.TopicArn == "" {
+		return rawMessage(id, body)
+	}
+	return unwrapEnvelope(sns)
+}
+
+// unwrapEnvelope the innermost notification of one republished from one topic to another, which arrives with the first
+// topic's envelope as its message
+func unwrapEnvelope(sns events.SNSEntity) events.SNSEntity {
+	inner := events.SNSEntity{}
+	for sns.Type == snsNotification && json.Unmarshal([]byte(sns.Message), &inner) == nil && inner.Type == snsNotification && inner.TopicArn != "" {
+		sns, inner = inner, events.SNSEntity{}
+	}
+	return sns
+}
+
+// rawMessage the notification for a message delivered without the SNS envelope
+func rawMessage(id string, body []byte) events.SNSEntity {
+	sns := events.SNSEntity{Type: snsNotification, MessageID: id, Message: string(body)}
+	if parser, ok := alarmParser(sns); ok && parser.Name != "alarm" {
+		if cloudWatchAlarmEvent, err := parser.Parse(sns); err == nil {
+			if message, err := json.Marshal(cloudWatchAlarmEvent); err == nil {
+				return events.SNSEntity{Type: snsNotification, MessageID: id, Subject: alarmSubject(cloudWatchAlarmEvent), Message: string(message)}
+			}
+		}
+	}
+
+	event := events.CloudWatchEvent{}
+	if err := json.Unmarshal(body, &event); err == nil && event.DetailType != "" {
+		return events.SNSEntity{Type: snsNotification, MessageID: id, Subject: event.DetailType, Message: string(body)}
+	}
+	return events.SNSEntity{Type: snsNotification, MessageID: id, Message: string(body)}
+}
+
+// messageFields splits a plain text message of "Name<separator>value" lines into its fields
+func messageFields(message string, separator string) map[string]string {
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(message))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), separator, 2)
+		if len(parts) == 2 {
+			fields[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return fields
+}
+
+// renderMessage renders an SNS message that isn't a CloudWatch alarm with the parser that detects it.  Messages none of
+// them detect, or the parser fails to parse, are passed through as their subject and body, with JSON bodies
+// pretty-printed unless PASSTHROUGH is raw, or dropped when PASSTHROUGH is drop.
+func renderMessage(ctx context.Context, sns events.SNSEntity) *OtherMessage {
+	// Alarms that reach here are missing something or didn't decode
+	if parser, ok := alarmParser(sns); ok {
+		if _, err := parser.Parse(sns); err != nil {
+			quarantine(ctx, parser.Name, sns.MessageID, []byte(sns.Message), err)
+		}
+	}
+
+	for _, parser := range messageParsers {
+		if !parser.Detect(sns) {
+			continue
+		}
+		var message *OtherMessage
+		err := trace(ctx, "parse "+parser.Name, func(ctx context.Context) (err error) {
+			message, err = parser.Parse(sns)
+			return err
+		})
+		if err != nil {
+			quarantine(ctx, parser.Name, sns.MessageID, []byte(sns.Message), err)
+			break
+		}
+		return message
+	}
+
+	if passthrough == passthroughDrop {
+		slog.InfoContext(ctx, "Dropping SNS message, it isn't a CloudWatch alarm", "message_id", sns.MessageID)
+		return nil
+	}
+
+	body := sns.Message
+	if passthrough == passthroughPretty {
+		indented := bytes.Buffer{}
+		if err := json.Indent(&indented, []byte(sns.Message), "", "  "); err == nil {
+			body = "```" + indented.String() + "```"
+		}
+	}
+	text, _ := truncate(body, slackTextLimit)
+
+	title := sns.Subject
+	if title == "" {
+		title = fmt.Sprintf("Message from %s", sns.TopicArn)
+	}
+	return &OtherMessage{Attachment: slack.Attachment{
+		Title:      title,
+		Text:       text,
+		Footer:     os.Getenv("AWS_LAMBDA_FUNCTION_NAME"),
+		FooterIcon: "https://d1d05r7k0qlw4w.cloudfront.net/dist-cbe91c5a8477701757ff6752aae4c6f892018972/img/favicon.ico",
+		Ts:         time.Now().Unix(),
+	}}
+}
+
+// postOtherMessages posts the messages that weren't alarms to their channels, returning the last error posting any of
+// them
+func postOtherMessages(ctx context.Context, messages []OtherMessage) error {
+	if len(messages) == 0 || !notificationsEnabled(ctx) {
+		return nil
+	}
+
+	channels := []string{}
+	attachments := map[string][]slack.Attachment{}
+	for _, message := range messages {
+		channel := message.Channel
+		if channel == "" {
+			channel = slackMonitorChannel
+		}
+		if _, ok := attachments[channel]; !ok {
+			channels = append(channels, channel)
+		}
+		attachments[channel] = append(attachments[channel], message.Attachment)
+	}
+
+	ctx, cancel := sendBudget(ctx)
+	defer cancel()
+	var postErr error
+	for _, channel := range channels {
+		if err := postAttachments(ctx, channel, attachments[channel]); err != nil {
+			postErr = err
+		}
+	}
+	return postErr
+}
+
+// messageAttribute the string value of an SNS message attribute
+func messageAttribute(sns events.SNSEntity, name string) string {
+	attribute, ok := sns.MessageAttributes[name].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	value, _ := attribute["Value"].(string)
+	return value
+}
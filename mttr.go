@@ -0,0 +1,98 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// How many of the most recent recoveries the rolling MTTR is averaged over
+const mttrRecoveries = 10
+
+// trackRecovery measures how long the alarm took to go from ALARM back to OK, adds it and the rolling MTTR to the OK
+// notification and publishes it as a metric
+func trackRecovery(ctx context.Context, n *notification) {
+	recovery, ok := recoveryTime(n)
+	if !ok {
+		return
+	}
+	n.recovery = recovery
+	recoveries, mttr := rollingMTTR(n.previousState.Recoveries, recovery)
+
+	n.slackAttachment.AttachmentField = append(n.slackAttachment.AttachmentField,
+		slack.AttachmentField{
+			Title: "Time to Recover",
+			Value: recovery.Round(time.Second).String(),
+			Short: true,
+		},
+		slack.AttachmentField{
+			Title: fmt.Sprintf("MTTR (last %d)", len(recoveries)),
+			Value: mttr.String(),
+			Short: true,
+		},
+	)
+
+	client, err := cloudWatchService()
+	if err != nil {
+		slog.WarnContext(ctx, "Creating the CloudWatch client failed", "error", err)
+		return
+	}
+	_, err = client.PutMetricDataWithContext(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace: aws.String(metricsNamespace),
+		MetricData: []*cloudwatch.MetricDatum{
+			{
+				MetricName: aws.String("TimeToRecover"),
+				Dimensions: []*cloudwatch.Dimension{
+					{Name: aws.String("AlarmName"), Value: aws.String(n.cloudWatchAlarmEvent.AlarmName)},
+				},
+				Unit:  aws.String(cloudwatch.StandardUnitSeconds),
+				Value: aws.Float64(recovery.Seconds()),
+			},
+		},
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Publishing the recovery time failed", "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "error", err)
+	}
+}
+
+// recoveryTime how long the alarm was in ALARM before the OK the notification is for, false when it isn't a recovery
+// from ALARM
+func recoveryTime(n *notification) (time.Duration, bool) {
+	if n.previousState == nil || n.previousState.State != "ALARM" || n.cloudWatchAlarmEvent.NewStateValue != "OK" {
+		return 0, false
+	}
+	recovery := stateChangeTime(n.cloudWatchAlarmEvent).Sub(n.previousState.TransitionTime)
+	return recovery, recovery >= 0
+}
+
+// rollingMTTR the most recent recoveries in seconds with the new one added, and their mean
+func rollingMTTR(previous []int64, recovery time.Duration) ([]int64, time.Duration) {
+	recoveries := append(append([]int64{}, previous...), int64(recovery/time.Second))
+	if len(recoveries) > mttrRecoveries {
+		recoveries = recoveries[len(recoveries)-mttrRecoveries:]
+	}
+	total := int64(0)
+	for _, seconds := range recoveries {
+		total += seconds
+	}
+	return recoveries, time.Duration(total/int64(len(recoveries))) * time.Second
+}
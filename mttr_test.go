@@ -0,0 +1,74 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecoveryTime(t *testing.T) {
+	alarmed := time.Date(2018, 7, 23, 14, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		previousState *AlarmState
+		newState      string
+		recovery      time.Duration
+		ok            bool
+	}{
+		{"recovered", &AlarmState{State: "ALARM", TransitionTime: alarmed}, "OK", 12*time.Minute + 30*time.Second, true},
+		{"no previous state", nil, "OK", 0, false},
+		{"from INSUFFICIENT_DATA", &AlarmState{State: "INSUFFICIENT_DATA", TransitionTime: alarmed}, "OK", 0, false},
+		{"back in ALARM", &AlarmState{State: "ALARM", TransitionTime: alarmed}, "ALARM", 0, false},
+		{"out of order", &AlarmState{State: "ALARM", TransitionTime: alarmed.Add(time.Hour)}, "OK", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := &notification{
+				cloudWatchAlarmEvent: CloudWatchAlarmEvent{
+					NewStateValue:   test.newState,
+					StateChangeTime: alarmed.Add(12*time.Minute + 30*time.Second).Format(stateChangeTimeLayout),
+				},
+				previousState: test.previousState,
+			}
+			recovery, ok := recoveryTime(n)
+			if ok != test.ok || (ok && recovery != test.recovery) {
+				t.Errorf("recoveryTime() = %s, %t, want %s, %t", recovery, ok, test.recovery, test.ok)
+			}
+		})
+	}
+}
+
+func TestRollingMTTR(t *testing.T) {
+	tests := []struct {
+		name       string
+		previous   []int64
+		recovery   time.Duration
+		recoveries int
+		mttr       time.Duration
+	}{
+		{"first", nil, 90 * time.Second, 1, 90 * time.Second},
+		{"averaged", []int64{30, 60}, 90 * time.Second, 3, time.Minute},
+		{"oldest dropped", []int64{1000, 10, 10, 10, 10, 10, 10, 10, 10, 10}, 10 * time.Second, mttrRecoveries, 10 * time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recoveries, mttr := rollingMTTR(test.previous, test.recovery)
+			if len(recoveries) != test.recoveries || mttr != test.mttr {
+				t.Errorf("rollingMTTR() = %d recoveries, %s, want %d, %s", len(recoveries), mttr, test.recoveries, test.mttr)
+			}
+		})
+	}
+}
@@ -0,0 +1,644 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+	"golang.org/x/sync/errgroup"
+)
+
+// notification an alarm event along with its severity, runbook and the slack attachment rendered for it.  In bot-token
+// mode channel and ts are filled in once the message is posted.  Suppressed notifications aren't sent, but their state
+// is still recorded.
+type notification struct {
+	subject              string
+	cloudWatchAlarmEvent CloudWatchAlarmEvent
+	severity             Severity
+	runbook              string
+	runbookSnippet       string
+	slackAttachment      slack.Attachment
+	channel              string
+	ts                   string
+	previousState        *AlarmState
+	flapping             bool
+	pending              bool
+	recovery             time.Duration
+	suppressed           string
+	quiet                bool
+	correlationKey       string
+	consecutiveAlarms    int
+	// deliveryID identifies the record that delivered the alarm, failed is set along with the destination when sending
+	// to any destination fails so the record can be retried
+	deliveryID  string
+	messageID   string
+	failed      error
+	destination string
+}
+
+// failedMutex guards failed and destination, the destinations are sent to at the same time
+var failedMutex sync.Mutex
+
+// markFailed records that sending the notification to the destination failed
+func (n *notification) markFailed(err error, destination string) {
+	failedMutex.Lock()
+	defer failedMutex.Unlock()
+	n.failed, n.destination = err, destination
+}
+
+// newNotification renders the alarm event delivered by the SNS message
+func newNotification(ctx context.Context, sns events.SNSEntity, cloudWatchAlarmEvent CloudWatchAlarmEvent) *notification {
+	severity := alarmSeverity(cloudWatchAlarmEvent)
+	if cloudWatchAlarmEvent.NewStateValue == "INSUFFICIENT_DATA" && insufficientDataPolicyFor(cloudWatchAlarmEvent) == insufficientDataDowngrade {
+		severity = SeverityInfo
+	}
+
+	text, truncated := truncate(cloudWatchAlarmEvent.NewStateReason, slackTextLimit)
+	if truncated && detailsBucket != "" {
+		link, err := publishDetails(ctx, sns.Subject, cloudWatchAlarmEvent)
+		if err != nil {
+			slog.WarnContext(ctx, "Publishing the details failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+		} else {
+			text = fmt.Sprintf("%s\n<%s|Full details>", text, link)
+		}
+	}
+
+	slackAttachment := slack.Attachment{
+		Color:      severityColor(severity, cloudWatchAlarmEvent.NewStateValue),
+		Title:      fmt.Sprintf("%s %s", severityBadge(severity, cloudWatchAlarmEvent.NewStateValue), sns.Subject),
+		Text:       text,
+		Footer:     os.Getenv("AWS_LAMBDA_FUNCTION_NAME"),
+		FooterIcon: "https://d1d05r7k0qlw4w.cloudfront.net/dist-cbe91c5a8477701757ff6752aae4c6f892018972/img/favicon.ico",
+		Ts:         time.Now().UnixNano() / int64(time.Second),
+		AttachmentField: []slack.AttachmentField{
+			{
+				Title: "AccountID",
+				Value: cloudWatchAlarmEvent.AWSAccountID,
+				Short: true,
+			},
+			{
+				Title: "Region",
+				Value: cloudWatchAlarmEvent.Region,
+				Short: true,
+			},
+			{
+				Title: "Period",
+				Value: fmt.Sprintf("%v", cloudWatchAlarmEvent.Trigger.Period),
+				Short: true,
+			},
+			{
+				Title: "Threshold",
+				Value: fmt.Sprintf("%v", cloudWatchAlarmEvent.Trigger.Threshold),
+				Short: true,
+			},
+			{
+				Title: "Evaluated Periods",
+				Value: fmt.Sprintf("%v", cloudWatchAlarmEvent.Trigger.EvaluationPeriods),
+				Short: true,
+			},
+			{
+				Title: "Comparison Operator",
+				Value: cloudWatchAlarmEvent.Trigger.ComparisonOperator,
+				Short: true,
+			},
+		},
+	}
+
+	if slackSNSFields && sns.TopicArn != "" {
+		slackAttachment.AttachmentField = append(slackAttachment.AttachmentField,
+			slack.AttachmentField{
+				Title: "Topic",
+				Value: sns.TopicArn[strings.LastIndex(sns.TopicArn, ":")+1:],
+				Short: true,
+			},
+			slack.AttachmentField{
+				Title: "MessageID",
+				Value: sns.MessageID,
+				Short: true,
+			},
+		)
+	}
+
+	runbook := runbookURL(cloudWatchAlarmEvent)
+	snippet := ""
+	if runbook != "" {
+		if runbookSnippets && featureEnabled(featureEnrichment) {
+			var err error
+			if snippet, err = runbookSnippet(ctx, runbook); err != nil {
+				slog.WarnContext(ctx, "Fetching the runbook snippet failed", "runbook", runbook, "error", err)
+			}
+		}
+
+		// Slack collapses long field values behind "Show more" so the snippet doesn't take over the channel
+		slackAttachment.AttachmentField = append(slackAttachment.AttachmentField, slack.AttachmentField{
+			Title: "Runbook",
+			Value: strings.TrimSpace(fmt.Sprintf("<%s|%s>\n%s", runbook, runbook, snippet)),
+			Short: false,
+		})
+	}
+
+	if slackSparkline && featureEnabled(featureEnrichment) {
+		values, err := metricHistory(ctx, cloudWatchAlarmEvent)
+		if err != nil {
+			slog.WarnContext(ctx, "Fetching the metric history failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+		} else if len(values) != 0 {
+			slackAttachment.AttachmentField = append(slackAttachment.AttachmentField, slack.AttachmentField{
+				Title: "Trend",
+				Value: sparkline(values),
+				Short: false,
+			})
+		}
+	}
+
+	return &notification{
+		subject:              sns.Subject,
+		cloudWatchAlarmEvent: cloudWatchAlarmEvent,
+		severity:             severity,
+		runbook:              runbook,
+		runbookSnippet:       snippet,
+		slackAttachment:      slackAttachment,
+	}
+}
+
+// evaluate records the alarm's transition and decides whether the notification should be suppressed
+func evaluate(ctx context.Context, n *notification, suppressions []Suppression) {
+	cloudWatchAlarmEvent := n.cloudWatchAlarmEvent
+
+	if stateStore != nil {
+		previousState, err := stateStore.GetAlarmState(ctx, cloudWatchAlarmEvent.AlarmARN)
+		if err != nil {
+			slog.WarnContext(ctx, "Loading the alarm state failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+		}
+		n.previousState = previousState
+
+		err = stateStore.PutTransition(ctx, Transition{
+			AlarmARN:       cloudWatchAlarmEvent.AlarmARN,
+			AlarmName:      cloudWatchAlarmEvent.AlarmName,
+			State:          cloudWatchAlarmEvent.NewStateValue,
+			TransitionTime: stateChangeTime(cloudWatchAlarmEvent),
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "Recording the transition failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+		}
+
+		if flapThreshold > 0 {
+			if err := detectFlapping(ctx, n); err != nil {
+				slog.WarnContext(ctx, "Detecting flapping failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+			}
+		}
+		trackRecovery(ctx, n)
+	}
+
+	if stateStore != nil {
+		holdUntilConsecutive(n)
+	}
+
+	if cloudWatchAlarmEvent.NewStateValue == "INSUFFICIENT_DATA" {
+		switch insufficientDataPolicyFor(cloudWatchAlarmEvent) {
+		case insufficientDataDrop:
+			n.suppressed = "INSUFFICIENT_DATA notifications are dropped for this alarm"
+		case insufficientDataDelay:
+			// Without a state store there's nothing to re-check later so the notification goes out right away
+			if stateStore != nil {
+				n.pending = true
+				n.suppressed = fmt.Sprintf("INSUFFICIENT_DATA notifications are delayed %s for this alarm", insufficientDataDelayPeriod)
+			}
+		}
+	}
+
+	suppress(ctx, n, suppressions)
+	if n.suppressed == "" {
+		if err := holdForDigest(ctx, n); err != nil {
+			slog.WarnContext(ctx, "Holding the notification for the digest failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+		}
+	}
+}
+
+// suppress decides whether the notification should be suppressed by the alarm's policies, its tags, the alarms it
+// depends on or the suppressions in effect
+func suppress(ctx context.Context, n *notification, suppressions []Suppression) {
+	cloudWatchAlarmEvent := n.cloudWatchAlarmEvent
+
+	if cloudWatchAlarmEvent.NewStateValue == "OK" {
+		suppressed, err := suppressOK(ctx, cloudWatchAlarmEvent)
+		if err != nil {
+			slog.WarnContext(ctx, "Checking whether OKs are suppressed failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+		} else if suppressed || severityOverride(n.severity).SuppressOK || accountOverride(cloudWatchAlarmEvent).SuppressOK {
+			n.suppressed = "OK notifications are suppressed for this alarm"
+		}
+	}
+
+	if severityOverride(n.severity).Suppress {
+		n.suppressed = fmt.Sprintf("%s notifications are suppressed", n.severity.Name())
+	}
+	if accountSuppressed(cloudWatchAlarmEvent) {
+		n.suppressed = fmt.Sprintf("suppressed for account %s", cloudWatchAlarmEvent.AWSAccountID)
+	}
+
+	tag, err := tagSuppression(ctx, cloudWatchAlarmEvent)
+	if err != nil {
+		slog.WarnContext(ctx, "Checking the suppression tags failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+	} else if tag != "" {
+		n.suppressed = fmt.Sprintf("tagged %s", tag)
+		n.quiet = true
+	}
+
+	if err := checkActionsDisabled(ctx, n); err != nil {
+		slog.WarnContext(ctx, "Checking whether the alarm's actions are disabled failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+	}
+
+	parent, err := dependencySuppression(ctx, cloudWatchAlarmEvent)
+	if err != nil {
+		slog.WarnContext(ctx, "Checking the alarm's dependencies failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+	} else if parent != "" {
+		n.suppressed = fmt.Sprintf("root cause alarm %s is in ALARM", parent)
+		n.quiet = true
+	}
+
+	suppression, err := activeSuppression(ctx, suppressions, cloudWatchAlarmEvent, alarmChannel(n))
+	if err != nil {
+		slog.WarnContext(ctx, "Checking the suppressions failed", "alarm_arn", cloudWatchAlarmEvent.AlarmARN, "error", err)
+	}
+	if suppression != nil {
+		n.suppressed = fmt.Sprintf("suppression %s %s", suppression.ID, suppression.Reason)
+		n.quiet = true
+	}
+}
+
+// reminderSuppressed why a reminder about the alarm still being in ALARM, a re-notification or escalation, shouldn't go
+// out, empty when it should.  There's nothing to remind anyone of when the ALARM was never notified, and one that's
+// suppressed now stays quiet the same as a new notification would.
+func reminderSuppressed(ctx context.Context, alarmState AlarmState, suppressions []Suppression) string {
+	if alarmState.LastNotified.Before(alarmState.TransitionTime) {
+		return "the ALARM was never notified"
+	}
+	n := &notification{
+		cloudWatchAlarmEvent: alarmState.Event,
+		severity:             alarmSeverity(alarmState.Event),
+		previousState:        &alarmState,
+	}
+	suppress(ctx, n, suppressions)
+	return n.suppressed
+}
+
+// dispatch sends every notification that wasn't suppressed and records the state of every alarm
+func dispatch(ctx context.Context, notifications []*notification) {
+	sortByPriority(notifications)
+
+	if stormThreshold > 0 && stateStore != nil {
+		if err := detectStorm(ctx, notifications); err != nil {
+			slog.WarnContext(ctx, "Detecting an alarm storm failed", "error", err)
+		}
+	}
+
+	// The kill switch only stops the sending, state is still recorded so nothing is lost once it's flipped back
+	if !notificationsEnabled(ctx) {
+		for _, n := range notifications {
+			if n.suppressed == "" {
+				n.suppressed = "notifications are disabled by " + killSwitchParameter
+			}
+			n.quiet = false
+		}
+	}
+
+	active := []*notification{}
+	quiet := []slack.Attachment{}
+	quietARNs := []string{}
+	for _, n := range notifications {
+		if n.suppressed != "" {
+			slog.InfoContext(ctx, "Suppressed notification", "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "message_id", n.messageID, "state", n.cloudWatchAlarmEvent.NewStateValue, "reason", n.suppressed)
+			if n.quiet {
+				quiet = append(quiet, n.slackAttachment)
+				quietARNs = append(quietARNs, n.cloudWatchAlarmEvent.AlarmARN)
+			}
+			continue
+		}
+		active = append(active, n)
+	}
+
+	if quietAuditChannel != "" && len(quiet) != 0 {
+		postAttachments(withAlarms(ctx, quietARNs...), quietAuditChannel, quiet)
+	}
+
+	// Correlated alarms are posted by correlate, leaving the rest to go out on their own
+	individual := active
+	if correlationWindow > 0 && featureEnabled(featureThreading) && len(active) != 0 {
+		individual = correlate(ctx, active)
+	}
+
+	// Destinations are sent to at the same time, each with its own deadline so a slow one can't hold up the rest, all
+	// within the one send budget
+	sendCtx, cancel := sendBudget(ctx)
+	defer cancel()
+	group := errgroup.Group{}
+	// DESTINATIONS can take the place of SLACK_WEBHOOK entirely
+	sendDestinations := destinations
+	if !featureEnabled(featureDestinations) {
+		sendDestinations = nil
+	}
+	if currentSlackWebhook() != "" || currentSlackBotToken() != "" || len(slackWorkspaces) != 0 || len(sendDestinations) == 0 {
+		group.Go(func() error {
+			ctx, cancel := context.WithTimeout(sendCtx, slackTimeout)
+			defer cancel()
+			return trace(ctx, "slack", func(ctx context.Context) error {
+				return sendSlack(ctx, active, individual)
+			})
+		})
+	}
+	if currentTeamsWebhook() != "" {
+		group.Go(func() error {
+			ctx, cancel := context.WithTimeout(sendCtx, teamsTimeout)
+			defer cancel()
+			return trace(ctx, "teams", func(ctx context.Context) error {
+				return sendTeamsCards(ctx, active)
+			})
+		})
+	}
+	for _, destination := range sendDestinations {
+		destination := destination
+		group.Go(func() error {
+			ctx, cancel := context.WithTimeout(sendCtx, destination.sendTimeout())
+			defer cancel()
+			return trace(ctx, destination.Name, func(ctx context.Context) error {
+				return destination.send(ctx, active)
+			})
+		})
+	}
+	// Each destination marks the notifications it failed to send, which is what the results are counted from
+	if err := group.Wait(); err != nil {
+		slog.ErrorContext(ctx, "Sending notifications failed", "error", err)
+	}
+
+	sent, failed := 0, 0
+	for _, n := range active {
+		if n.failed != nil {
+			failed++
+		} else {
+			sent++
+			// Dimensioned by alarm so the noisiest ones can be graphed and alerted on
+			emitMetric("AlarmNotifications", "Count", 1, map[string]string{"AlarmName": n.cloudWatchAlarmEvent.AlarmName})
+		}
+	}
+	emitMetric("NotificationsSent", "Count", float64(sent), nil)
+	emitMetric("NotificationsFailed", "Count", float64(failed), nil)
+	emitMetric("NotificationsSuppressed", "Count", float64(len(notifications)-len(active)), nil)
+
+	if correlationWindow > 0 && featureEnabled(featureThreading) && stateStore != nil {
+		closeIncidents(ctx, notifications)
+	}
+
+	if stateStore != nil {
+		for _, n := range notifications {
+			alarmState := AlarmState{}
+			if n.previousState != nil {
+				alarmState = *n.previousState
+			}
+			// An event older than the stored state, like a replayed dead letter, doesn't turn the state back
+			if n.previousState != nil && stateChangeTime(n.cloudWatchAlarmEvent).Before(alarmState.TransitionTime) {
+				continue
+			}
+			// A new state starts a fresh acknowledgement and escalation cycle
+			if alarmState.State != n.cloudWatchAlarmEvent.NewStateValue {
+				alarmState.AcknowledgedBy, alarmState.AcknowledgedAt, alarmState.Escalated = "", time.Time{}, false
+				alarmState.Renotifications = 0
+			}
+			alarmState.AlarmARN = n.cloudWatchAlarmEvent.AlarmARN
+			alarmState.AlarmName = n.cloudWatchAlarmEvent.AlarmName
+			alarmState.State = n.cloudWatchAlarmEvent.NewStateValue
+			alarmState.TransitionTime = stateChangeTime(n.cloudWatchAlarmEvent)
+			alarmState.Flapping = n.flapping
+			alarmState.Pending = n.pending
+			alarmState.ConsecutiveAlarms = n.consecutiveAlarms
+			alarmState.Subject = n.subject
+			alarmState.Event = n.cloudWatchAlarmEvent
+			if n.ts != "" {
+				alarmState.Channel, alarmState.MessageTs = n.channel, n.ts
+				alarmState.Details = ""
+				if slackBlocks && featureEnabled(featureThreading) && detailsOnRequest() {
+					if details, err := json.Marshal(n.slackAttachment); err == nil {
+						alarmState.Details = string(details)
+					}
+				}
+			}
+			if n.suppressed == "" && n.failed == nil {
+				alarmState.LastNotified = time.Now()
+			}
+			if n.recovery > 0 {
+				alarmState.Recoveries = append(alarmState.Recoveries, int64(n.recovery/time.Second))
+				if len(alarmState.Recoveries) > mttrRecoveries {
+					alarmState.Recoveries = alarmState.Recoveries[len(alarmState.Recoveries)-mttrRecoveries:]
+				}
+			}
+
+			if err := stateStore.PutAlarmState(ctx, alarmState); err != nil {
+				slog.ErrorContext(ctx, "Saving the alarm state failed", "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "error", err)
+			}
+		}
+	}
+}
+
+// sendSlack posts the notifications to the monitor channel, each on its own in bot-token mode or together through the
+// webhook, returning the last error
+func sendSlack(ctx context.Context, active []*notification, individual []*notification) error {
+	if len(active) == 0 {
+		slog.WarnContext(ctx, "No Slack sent, there are no active notifications")
+		return nil
+	}
+
+	individual, others := otherWorkspaces(individual)
+	sendErr := sendWorkspaces(ctx, others)
+	if currentSlackBotToken() != "" {
+		// In bot-token mode every alarm gets its own message so it can carry metadata describing that alarm
+		for _, n := range individual {
+			ctx := withLogAttrs(withAlarms(ctx, n.cloudWatchAlarmEvent.AlarmARN), "message_id", n.messageID)
+
+			// Look for the ALARM being resolved before posting, otherwise the OK itself is the latest message for the alarm.
+			// conversations.history only accepts channel IDs so SLACK_MONITOR_CHANNEL needs to be one for this to work.
+			channel := alarmChannel(n)
+			alarmTs := ""
+			if slackResolvedReaction != "" && n.cloudWatchAlarmEvent.NewStateValue == "OK" {
+				ts, err := findAlarmMessage(ctx, channel, n.cloudWatchAlarmEvent.AlarmARN)
+				if err != nil {
+					slog.WarnContext(ctx, "Finding the ALARM message failed", "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "channel", channel, "error", err)
+				}
+				alarmTs = ts
+			}
+
+			message := SlackMessage{
+				Channel:     channel,
+				Attachments: []slack.Attachment{n.slackAttachment},
+				Metadata:    alarmMetadata(n.cloudWatchAlarmEvent, n.severity),
+			}
+			// The block summary leaves the details to its thread
+			threaded := slackBlocks && featureEnabled(featureThreading)
+			if threaded {
+				message.Text = n.subject
+				message.Blocks = summaryBlocks(n)
+				message.Attachments = nil
+			}
+			// Buttons need somewhere to send their callbacks, which the signing secret says has been set up
+			if currentSlackSigningSecret() != "" && stateStore != nil && n.cloudWatchAlarmEvent.NewStateValue == "ALARM" {
+				message.Text = n.subject
+				message.Blocks = append(message.Blocks, alarmActions(n))
+			}
+
+			resp, err := postMessage(ctx, message)
+			if err != nil {
+				slog.ErrorContext(ctx, "Sending notification failed", "destination", "slack:"+channel, "error", err)
+				n.markFailed(err, "slack:"+channel)
+				sendErr = err
+				continue
+			}
+			slog.InfoContext(ctx, "Sent notification", "destination", "slack:"+channel, "ts", resp.Ts)
+			n.channel, n.ts = resp.Channel, resp.Ts
+			if n.correlationKey != "" && stateStore != nil {
+				openGroup(ctx, n)
+			}
+
+			if threaded && !detailsOnRequest() {
+				details, err := postMessage(ctx, SlackMessage{
+					Channel:     resp.Channel,
+					ThreadTs:    resp.Ts,
+					Attachments: []slack.Attachment{n.slackAttachment},
+				})
+				if err != nil {
+					slog.WarnContext(ctx, "Posting the details in the thread failed", "destination", "slack:"+channel, "error", err)
+				} else {
+					slog.InfoContext(ctx, "Posted the details in the thread", "destination", "slack:"+channel, "ts", details.Ts)
+				}
+			}
+
+			if alarmTs != "" {
+				if err := addReaction(ctx, resp.Channel, alarmTs, slackResolvedReaction); err != nil {
+					slog.WarnContext(ctx, "Reacting to the ALARM message failed", "destination", "slack:"+channel, "error", err)
+				}
+			}
+		}
+	} else {
+		// The webhook posts each channel's alarms together
+		channels := []string{}
+		byChannel := map[string][]*notification{}
+		for _, n := range individual {
+			channel := alarmChannel(n)
+			if _, ok := byChannel[channel]; !ok {
+				channels = append(channels, channel)
+			}
+			byChannel[channel] = append(byChannel[channel], n)
+		}
+		for _, channel := range channels {
+			slackAttachments := []slack.Attachment{}
+			alarmARNs := []string{}
+			for _, n := range byChannel[channel] {
+				slackAttachments = append(slackAttachments, n.slackAttachment)
+				alarmARNs = append(alarmARNs, n.cloudWatchAlarmEvent.AlarmARN)
+			}
+			if err := postAttachments(withAlarms(ctx, alarmARNs...), channel, slackAttachments); err != nil {
+				for _, n := range byChannel[channel] {
+					n.markFailed(err, "slack-webhook:"+channel)
+				}
+				sendErr = err
+			}
+		}
+	}
+	return sendErr
+}
+
+// sendTeamsCards sends each notification to Teams as an adaptive card, marking the ones that fail and returning the
+// last error
+func sendTeamsCards(ctx context.Context, active []*notification) error {
+	var sendErr error
+	for _, n := range active {
+		ctx := withLogAttrs(withAlarms(ctx, n.cloudWatchAlarmEvent.AlarmARN), "message_id", n.messageID)
+		if err := sendTeams(ctx, adaptiveCard(n)); err != nil {
+			slog.ErrorContext(ctx, "Sending notification failed", "destination", "teams", "error", err)
+			n.markFailed(err, "teams")
+			sendErr = err
+		}
+	}
+	return sendErr
+}
+
+// chunkAttachments splits the attachments into posts Slack will accept.  Slack only allows 100 attachments in one post
+// and rejects payloads that are too large, so each attachment's text is capped and a post is closed off once it would
+// go over either limit.
+func chunkAttachments(slackAttachments []slack.Attachment) [][]slack.Attachment {
+	chunks := [][]slack.Attachment{}
+	chunk := []slack.Attachment{}
+	size := 0
+	for _, attachment := range slackAttachments {
+		attachment.Text, _ = truncate(attachment.Text, slackTextLimit)
+		fields := make([]slack.AttachmentField, len(attachment.AttachmentField))
+		for i, field := range attachment.AttachmentField {
+			field.Value, _ = truncate(field.Value, slackTextLimit)
+			fields[i] = field
+		}
+		attachment.AttachmentField = fields
+
+		encoded, _ := json.Marshal(attachment)
+		if len(chunk) != 0 && (len(chunk) >= slackAttachmentsChunkSize || size+len(encoded) > slackPayloadLimit) {
+			chunks = append(chunks, chunk)
+			chunk, size = []slack.Attachment{}, 0
+		}
+		chunk = append(chunk, attachment)
+		size += len(encoded)
+	}
+	if len(chunk) != 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// postAttachments posts the attachments to the channel, with the bot token when there is one and the webhook otherwise,
+// returning the last error posting any of them
+func postAttachments(ctx context.Context, channel string, slackAttachments []slack.Attachment) error {
+	var postErr error
+	for _, chunkedSlackAttachments := range chunkAttachments(slackAttachments) {
+		if currentSlackBotToken() != "" {
+			resp, err := postMessage(ctx, SlackMessage{
+				Channel:     channel,
+				Attachments: chunkedSlackAttachments,
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "Sending notification failed", "destination", "slack:"+channel, "error", err)
+				postErr = err
+			} else {
+				slog.InfoContext(ctx, "Sent notification", "destination", "slack:"+channel, "ts", resp.Ts)
+			}
+			continue
+		}
+
+		payload := slack.Payload{
+			Channel:     channel,
+			Attachments: chunkedSlackAttachments,
+		}
+		resp, err := sendSlackWebhook(ctx, payload)
+		if err != nil {
+			slog.ErrorContext(ctx, "Sending notification failed", "destination", "slack-webhook", "error", err)
+			postErr = err
+		} else {
+			slog.InfoContext(ctx, "Sent notification", "destination", "slack-webhook", "status", resp.Status)
+		}
+	}
+	return postErr
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+func TestChunkAttachments(t *testing.T) {
+	slackAttachmentsChunkSize = 100
+	attachments := func(count int, text string) []slack.Attachment {
+		slackAttachments := make([]slack.Attachment, count)
+		for i := range slackAttachments {
+			slackAttachments[i] = slack.Attachment{Title: "alarm", Text: text}
+		}
+		return slackAttachments
+	}
+	tests := []struct {
+		name             string
+		slackAttachments []slack.Attachment
+		chunks           int
+		attachments      int
+	}{
+		{"none", nil, 0, 0},
+		{"one post", attachments(3, "disk full"), 1, 3},
+		{"too many attachments", attachments(150, "disk full"), 2, 150},
+		{"too large", attachments(30, strings.Repeat("x", 2*slackTextLimit)), 3, 30},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chunks := chunkAttachments(test.slackAttachments)
+			if len(chunks) != test.chunks {
+				t.Fatalf("chunkAttachments() = %d chunks, want %d", len(chunks), test.chunks)
+			}
+			attachments := 0
+			for _, chunk := range chunks {
+				attachments += len(chunk)
+				if len(chunk) > slackAttachmentsChunkSize {
+					t.Errorf("chunk has %d attachments, over %d", len(chunk), slackAttachmentsChunkSize)
+				}
+				encoded, _ := json.Marshal(chunk)
+				if len(encoded) > slackPayloadLimit {
+					t.Errorf("chunk is %d bytes, over %d", len(encoded), slackPayloadLimit)
+				}
+				for _, attachment := range chunk {
+					if utf8.RuneCountInString(attachment.Text) > slackTextLimit {
+						t.Errorf("attachment text is %d runes, over %d", utf8.RuneCountInString(attachment.Text), slackTextLimit)
+					}
+				}
+			}
+			if attachments != test.attachments {
+				t.Errorf("chunkAttachments() kept %d attachments, want %d", attachments, test.attachments)
+			}
+		})
+	}
+}
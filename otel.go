@@ -0,0 +1,130 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const otelScope = "github.com/jmoney/cloudwatch-alarm-notifier-lambda"
+
+var (
+	otelTracer         oteltrace.Tracer
+	otelMeter          metric.Meter
+	otelTracerProvider *sdktrace.TracerProvider
+	otelMeterProvider  *sdkmetric.MeterProvider
+
+	// otelInstruments the instrument for each metric name, created the first time it's recorded
+	otelInstruments      = map[string]interface{}{}
+	otelInstrumentsMutex sync.Mutex
+)
+
+// newOpenTelemetry exports traces and metrics over OTLP/HTTP.  The exporters send to the ADOT collector layer on
+// localhost:4318 unless OTEL_EXPORTER_OTLP_ENDPOINT points them somewhere else, and pick up the rest of the standard
+// OTEL_* env vars themselves.
+func newOpenTelemetry(ctx context.Context) error {
+	traceExporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return err
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	otelTracerProvider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter))
+	otelMeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)))
+	otel.SetTracerProvider(otelTracerProvider)
+	otel.SetMeterProvider(otelMeterProvider)
+	otelTracer = otelTracerProvider.Tracer(otelScope)
+	otelMeter = otelMeterProvider.Meter(otelScope)
+	return nil
+}
+
+// flushOpenTelemetry exports whatever has been batched up, since the Lambda can be frozen or torn down as soon as the
+// invocation returns
+func flushOpenTelemetry(ctx context.Context) {
+	if err := otelTracerProvider.ForceFlush(ctx); err != nil {
+		slog.WarnContext(ctx, "Flushing the traces failed", "error", err)
+	}
+	if err := otelMeterProvider.ForceFlush(ctx); err != nil {
+		slog.WarnContext(ctx, "Flushing the metrics failed", "error", err)
+	}
+}
+
+// otelSpan runs fn in a span called name, marking the span as errored when fn fails
+func otelSpan(ctx context.Context, name string, fn func(context.Context) error) error {
+	ctx, span := otelTracer.Start(ctx, name)
+	defer span.End()
+	err := fn(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// otelMetric records the metric with OpenTelemetry.  Counts are added to a counter, anything else is recorded in a
+// histogram.
+func otelMetric(name string, unit string, value float64, dimensions map[string]string) {
+	attributes := []attribute.KeyValue{}
+	for dimension, dimensionValue := range dimensions {
+		attributes = append(attributes, attribute.String(dimension, dimensionValue))
+	}
+
+	otelInstrumentsMutex.Lock()
+	instrument, ok := otelInstruments[name]
+	if !ok {
+		var err error
+		if unit == "Count" {
+			instrument, err = otelMeter.Float64Counter(name, metric.WithUnit("{count}"))
+		} else {
+			instrument, err = otelMeter.Float64Histogram(name, metric.WithUnit(otelUnits[unit]))
+		}
+		if err != nil {
+			otelInstrumentsMutex.Unlock()
+			slog.Warn("Creating the metric instrument failed", "metric", name, "error", err)
+			return
+		}
+		otelInstruments[name] = instrument
+	}
+	otelInstrumentsMutex.Unlock()
+
+	switch instrument := instrument.(type) {
+	case metric.Float64Counter:
+		instrument.Add(context.Background(), value, metric.WithAttributes(attributes...))
+	case metric.Float64Histogram:
+		instrument.Record(context.Background(), value, metric.WithAttributes(attributes...))
+	}
+}
+
+// otelUnits the UCUM unit for each CloudWatch unit emitted
+var otelUnits = map[string]string{
+	"Milliseconds": "ms",
+	"Seconds":      "s",
+	"Bytes":        "By",
+}
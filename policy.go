@@ -0,0 +1,134 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// How INSUFFICIENT_DATA notifications are handled
+const (
+	insufficientDataNotify    = "notify"
+	insufficientDataDrop      = "drop"
+	insufficientDataDowngrade = "downgrade"
+	insufficientDataDelay     = "delay"
+)
+
+// Alarms override the global policy in their description, e.g. "insufficient_data: drop"
+var insufficientDataMarker = regexp.MustCompile(`(?i)insufficient[_ ]data\s*[:=]\s*(notify|drop|downgrade|delay)\b`)
+
+// insufficientDataPolicyFor the INSUFFICIENT_DATA policy for the alarm
+func insufficientDataPolicyFor(cloudWatchAlarmEvent CloudWatchAlarmEvent) string {
+	if match := insufficientDataMarker.FindStringSubmatch(cloudWatchAlarmEvent.AlarmDescription); match != nil {
+		return strings.ToLower(match[1])
+	}
+	if policy := severityOverride(alarmSeverity(cloudWatchAlarmEvent)).InsufficientDataPolicy; policy != "" {
+		return policy
+	}
+	return insufficientDataPolicy
+}
+
+// Alarms override how many consecutive ALARM deliveries it takes to notify in their description, e.g. "notify_after: 3"
+var notifyAfterMarker = regexp.MustCompile(`(?i)notify[_ ]after\s*[:=]\s*(\d+)\b`)
+
+// notifyAfterFor how many consecutive ALARM deliveries it takes before the alarm is notified
+func notifyAfterFor(cloudWatchAlarmEvent CloudWatchAlarmEvent) int {
+	if match := notifyAfterMarker.FindStringSubmatch(cloudWatchAlarmEvent.AlarmDescription); match != nil {
+		if count, err := strconv.Atoi(match[1]); err == nil {
+			return count
+		}
+	}
+	return notifyAfter
+}
+
+// holdUntilConsecutive holds ALARM notifications as pending until the alarm has reported ALARM the required number of
+// times in a row, and drops the OK of an alarm that recovered before it was ever notified
+func holdUntilConsecutive(n *notification) {
+	if n.previousState != nil && n.previousState.State == "ALARM" {
+		n.consecutiveAlarms = n.previousState.ConsecutiveAlarms
+	}
+
+	switch n.cloudWatchAlarmEvent.NewStateValue {
+	case "ALARM":
+		n.consecutiveAlarms++
+		if required := notifyAfterFor(n.cloudWatchAlarmEvent); n.consecutiveAlarms < required {
+			n.pending = true
+			n.suppressed = fmt.Sprintf("ALARM reported %d of the %d consecutive times required to notify", n.consecutiveAlarms, required)
+		}
+	case "OK":
+		if n.previousState != nil && n.previousState.State == "ALARM" && n.previousState.Pending {
+			n.suppressed = "recovered before the ALARM was notified"
+		}
+	}
+}
+
+// recheckPending sends the delayed INSUFFICIENT_DATA notifications for alarms that have stayed in INSUFFICIENT_DATA
+// for the whole delay, and the held ALARM notifications for alarms still in ALARM an evaluation period later.  Alarms
+// that changed state in the meantime have already had their pending notification cleared by the transition.
+func recheckPending(ctx context.Context) error {
+	alarmStates, err := stateStore.AlarmStates(ctx)
+	if err != nil {
+		return err
+	}
+
+	suppressions, err := currentSuppressions(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Loading the suppressions failed", "error", err)
+	}
+
+	notifications := []*notification{}
+	for i := range alarmStates {
+		alarmState := alarmStates[i]
+		if !alarmState.Pending {
+			continue
+		}
+		switch alarmState.State {
+		case "INSUFFICIENT_DATA":
+			if time.Since(alarmState.TransitionTime) < insufficientDataDelayPeriod {
+				continue
+			}
+		case "ALARM":
+			if time.Since(alarmState.TransitionTime) < time.Duration(alarmState.Event.Trigger.Period)*time.Second {
+				continue
+			}
+		default:
+			continue
+		}
+
+		n := newNotification(ctx, events.SNSEntity{Subject: alarmState.Subject}, alarmState.Event)
+		n.previousState = &alarmState
+		n.consecutiveAlarms = alarmState.ConsecutiveAlarms
+		// The transition was recorded when it came in, what's left is everything that could still stop or reroute it
+		applyTemplate(n)
+		suppress(ctx, n, suppressions)
+		if n.suppressed == "" {
+			if err := holdForDigest(ctx, n); err != nil {
+				slog.WarnContext(ctx, "Holding the notification for the digest failed", "alarm_arn", alarmState.AlarmARN, "error", err)
+			}
+		}
+		notifications = append(notifications, n)
+	}
+
+	dispatch(ctx, notifications)
+	return nil
+}
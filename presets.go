@@ -0,0 +1,72 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// presets bundles of settings selected by PRESET, so a new deployment can start from one and only set what it wants
+// different.  A preset sits just above the defaults, anything set in the config file, SSM, AppConfig or the env vars
+// still wins.
+var presets = map[string]map[string]string{
+	// minimal one compact line per alarm, with no lookups to enrich it and INSUFFICIENT_DATA dropped
+	"minimal": {
+		"FEATURE_ENRICHMENT":       "false",
+		"INSUFFICIENT_DATA_POLICY": insufficientDataDrop,
+		"RETRY_ATTEMPTS":           "2",
+		"RUNBOOK_SNIPPETS":         "false",
+		"SLACK_BLOCKS":             "false",
+		"SLACK_SNS_FIELDS":         "false",
+		"SLACK_SPARKLINE":          "false",
+	},
+	// verbose everything the notifier can say about an alarm
+	"verbose": {
+		"INSUFFICIENT_DATA_POLICY": insufficientDataNotify,
+		"RETRY_ATTEMPTS":           "3",
+		"RUNBOOK_SNIPPETS":         "true",
+		"SLACK_SNS_FIELDS":         "true",
+		"SLACK_SPARKLINE":          "true",
+	},
+	// oncall only what needs someone, reminded and escalated until it's handled, and tried harder to deliver
+	"oncall": {
+		"ESCALATION_SLA":           "15m",
+		"FLAP_THRESHOLD":           "4",
+		"INSUFFICIENT_DATA_POLICY": insufficientDataDowngrade,
+		"NOTIFY_AFTER":             "2",
+		"RENOTIFY_INTERVAL":        "30m",
+		"RETRY_ATTEMPTS":           "5",
+		"RUNBOOK_SNIPPETS":         "true",
+		"SLACK_BLOCKS":             "true",
+		"STORM_THRESHOLD":          "10",
+	},
+}
+
+// applyPreset puts the settings of the preset PRESET names in place, or takes them away when it's unset
+func applyPreset() {
+	name := strings.ToLower(getenv("PRESET"))
+	preset, ok := presets[name]
+	if name != "" && !ok {
+		names := []string{}
+		for name := range presets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		configProblem("PRESET", fmt.Errorf("%q isn't one of %s", name, strings.Join(names, ", ")))
+	}
+	setConfigValues(configSourcePreset, preset)
+}
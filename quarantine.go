@@ -0,0 +1,83 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// QuarantineRecord what's logged about a payload that couldn't be parsed
+type QuarantineRecord struct {
+	Parser string `json:"parser"`
+	ID     string `json:"id,omitempty"`
+	Error  string `json:"error"`
+	Bytes  int    `json:"bytes"`
+	S3Key  string `json:"s3Key,omitempty"`
+}
+
+// quarantine logs a payload that couldn't be parsed and keeps a copy of it under QUARANTINE_PREFIX in
+// QUARANTINE_BUCKET and in QUARANTINE_CHANNEL, whichever are set, so gaps in parsing get noticed and can be fixed
+func quarantine(ctx context.Context, parser string, id string, payload []byte, parseErr error) {
+	record := QuarantineRecord{Parser: parser, ID: id, Error: parseErr.Error(), Bytes: len(payload)}
+
+	if quarantineBucket != "" {
+		name := id
+		if name == "" {
+			name = fmt.Sprintf("%d", time.Now().UnixNano())
+		}
+		key := fmt.Sprintf("%s%s/%s/%s.json", quarantinePrefix, parser, time.Now().UTC().Format("2006-01-02"), name)
+		client, err := s3Service()
+		if err == nil {
+			_, err = client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+				Bucket: aws.String(quarantineBucket),
+				Key:    aws.String(key),
+				Body:   bytes.NewReader(payload),
+			})
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Uploading the quarantined payload failed", "parser", parser, "id", id, "error", err)
+		} else {
+			record.S3Key = key
+		}
+	}
+
+	emitMetric("ParseFailures", "Count", 1, map[string]string{"Parser": parser})
+	slog.ErrorContext(ctx, "Quarantined payload", "parser", record.Parser, "id", record.ID, "error", record.Error, "bytes", record.Bytes, "s3_key", record.S3Key)
+
+	if quarantineChannel != "" {
+		text, _ := truncate(string(payload), slackTextLimit-len(record.Error)-16)
+		fields := []slack.AttachmentField{{Title: "Parser", Value: parser, Short: true}}
+		if record.S3Key != "" {
+			fields = append(fields, slack.AttachmentField{Title: "S3", Value: fmt.Sprintf("s3://%s/%s", quarantineBucket, record.S3Key), Short: false})
+		}
+		if err := postAttachments(ctx, quarantineChannel, []slack.Attachment{{
+			Color:           "warning",
+			Title:           fmt.Sprintf(":biohazard_sign: Couldn't parse message %s", id),
+			Text:            fmt.Sprintf("%s\n```%s```", record.Error, text),
+			Ts:              time.Now().Unix(),
+			AttachmentField: fields,
+		}}); err != nil {
+			slog.WarnContext(ctx, "Posting the quarantined payload failed", "parser", parser, "id", id, "error", err)
+		}
+	}
+}
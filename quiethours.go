@@ -0,0 +1,147 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// QuietHours the daily window, e.g. 22:00-07:00, during which non-critical notifications are held for the morning digest
+type QuietHours struct {
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// QueuedNotification a notification held during quiet hours
+type QueuedNotification struct {
+	ID       string               `dynamodbav:"ID"`
+	Subject  string               `dynamodbav:"Subject"`
+	Severity Severity             `dynamodbav:"Severity"`
+	Event    CloudWatchAlarmEvent `dynamodbav:"Event"`
+	QueuedAt time.Time            `dynamodbav:"QueuedAt"`
+}
+
+// ParseQuietHours parses a HH:MM-HH:MM window in the given time zone
+func ParseQuietHours(value string, zone string) (*QuietHours, error) {
+	parts := strings.SplitN(value, "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("quiet hours %q should look like 22:00-07:00", value)
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, err
+	}
+	return &QuietHours{
+		Start:    time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute,
+		End:      time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute,
+		Location: location,
+	}, nil
+}
+
+// Contains whether the time falls within quiet hours, which wrap past midnight when they end before they start
+func (quietHours QuietHours) Contains(at time.Time) bool {
+	at = at.In(quietHours.Location)
+	of := time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+	if quietHours.Start <= quietHours.End {
+		return of >= quietHours.Start && of < quietHours.End
+	}
+	return of >= quietHours.Start || of < quietHours.End
+}
+
+// holdForDigest queues the notification for the morning digest if it arrived during quiet hours and isn't critical
+func holdForDigest(ctx context.Context, n *notification) error {
+	if quietHours == nil || stateStore == nil || !featureEnabled(featureDigests) || n.severity == SeverityCritical || !quietHours.Contains(time.Now()) {
+		return nil
+	}
+
+	err := stateStore.QueueNotification(ctx, QueuedNotification{
+		ID:       fmt.Sprintf("%s#%s", time.Now().UTC().Format(transitionKeyLayout), n.cloudWatchAlarmEvent.AlarmARN),
+		Subject:  n.subject,
+		Severity: n.severity,
+		Event:    n.cloudWatchAlarmEvent,
+		QueuedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	n.suppressed = "held for the morning digest during quiet hours"
+	return nil
+}
+
+// morningDigest posts everything held during quiet hours as a single message to each channel it's routed to, oldest
+// first.  What's held is only deleted once it's been posted, so a failed post is tried again the next morning.
+func morningDigest(ctx context.Context) error {
+	queued, err := stateStore.QueuedNotifications(ctx)
+	if err != nil {
+		return err
+	}
+	if len(queued) == 0 {
+		slog.InfoContext(ctx, "Nothing held during quiet hours")
+		return nil
+	}
+
+	channels := []string{}
+	byChannel := map[string][]QueuedNotification{}
+	for _, q := range queued {
+		channel := ownChannel(q.Event, q.Severity)
+		if _, ok := byChannel[channel]; !ok {
+			channels = append(channels, channel)
+		}
+		byChannel[channel] = append(byChannel[channel], q)
+	}
+
+	location := time.UTC
+	if quietHours != nil {
+		location = quietHours.Location
+	}
+	var postErr error
+	for _, channel := range channels {
+		lines := []string{}
+		for _, q := range byChannel[channel] {
+			reason, _ := truncate(q.Event.NewStateReason, summaryReasonLimit)
+			lines = append(lines, fmt.Sprintf("%s `%s` %s *%s* %s", q.QueuedAt.In(location).Format("15:04"),
+				q.Severity, q.Event.NewStateValue, q.Event.AlarmName, reason))
+		}
+		text, _ := truncate(strings.Join(lines, "\n"), slackTextLimit)
+		err := postAttachments(ctx, channel, []slack.Attachment{{
+			Color: "#439FE0",
+			Title: fmt.Sprintf(":sunrise: %d notifications held during quiet hours", len(byChannel[channel])),
+			Text:  text,
+			Ts:    time.Now().Unix(),
+		}})
+		if err == nil {
+			err = stateStore.DeleteQueuedNotifications(ctx, byChannel[channel])
+		}
+		if err != nil {
+			postErr = err
+		}
+	}
+	return postErr
+}
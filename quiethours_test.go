@@ -0,0 +1,81 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		zone  string
+		start time.Duration
+		end   time.Duration
+		ok    bool
+	}{
+		{"overnight", "22:00-07:00", "UTC", 22 * time.Hour, 7 * time.Hour, true},
+		{"same day", "12:30 - 13:45", "UTC", 12*time.Hour + 30*time.Minute, 13*time.Hour + 45*time.Minute, true},
+		{"no end", "22:00", "UTC", 0, 0, false},
+		{"not a time", "22:00-7pm", "UTC", 0, 0, false},
+		{"unknown zone", "22:00-07:00", "Nowhere/Special", 0, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quietHours, err := ParseQuietHours(test.value, test.zone)
+			if (err == nil) != test.ok {
+				t.Fatalf("ParseQuietHours(%q, %q) error = %v, want ok %t", test.value, test.zone, err, test.ok)
+			}
+			if err == nil && (quietHours.Start != test.start || quietHours.End != test.end) {
+				t.Errorf("ParseQuietHours(%q, %q) = %s-%s, want %s-%s", test.value, test.zone, quietHours.Start, quietHours.End, test.start, test.end)
+			}
+		})
+	}
+}
+
+func TestQuietHoursContains(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	overnight := QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour, Location: time.UTC}
+	daytime := QuietHours{Start: 12 * time.Hour, End: 13 * time.Hour, Location: time.UTC}
+	local := QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour, Location: newYork}
+	tests := []struct {
+		name       string
+		quietHours QuietHours
+		at         time.Time
+		contains   bool
+	}{
+		{"before midnight", overnight, time.Date(2018, 7, 23, 23, 30, 0, 0, time.UTC), true},
+		{"after midnight", overnight, time.Date(2018, 7, 24, 3, 0, 0, 0, time.UTC), true},
+		{"at the start", overnight, time.Date(2018, 7, 23, 22, 0, 0, 0, time.UTC), true},
+		{"at the end", overnight, time.Date(2018, 7, 24, 7, 0, 0, 0, time.UTC), false},
+		{"during the day", overnight, time.Date(2018, 7, 24, 12, 0, 0, 0, time.UTC), false},
+		{"same day window", daytime, time.Date(2018, 7, 24, 12, 59, 0, 0, time.UTC), true},
+		{"outside same day window", daytime, time.Date(2018, 7, 24, 23, 0, 0, 0, time.UTC), false},
+		{"in the window's zone", local, time.Date(2018, 7, 24, 3, 0, 0, 0, time.UTC), true},
+		{"outside the window's zone", local, time.Date(2018, 7, 24, 12, 0, 0, 0, time.UTC), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if contains := test.quietHours.Contains(test.at); contains != test.contains {
+				t.Errorf("Contains(%s) = %t, want %t", test.at, contains, test.contains)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// rdsEventCategories the category of the RDS events worth calling out, by event ID
+var rdsEventCategories = map[string]string{
+	"RDS-EVENT-0013": "failover",
+	"RDS-EVENT-0015": "failover",
+	"RDS-EVENT-0034": "failover",
+	"RDS-EVENT-0049": "failover",
+	"RDS-EVENT-0050": "failover",
+	"RDS-EVENT-0051": "failover",
+	"RDS-EVENT-0065": "failover",
+	"RDS-EVENT-0007": "low storage",
+	"RDS-EVENT-0089": "low storage",
+	"RDS-EVENT-0026": "maintenance",
+	"RDS-EVENT-0027": "maintenance",
+	"RDS-EVENT-0047": "maintenance",
+	"RDS-EVENT-0055": "maintenance",
+	"RDS-EVENT-0056": "maintenance",
+	"RDS-EVENT-0004": "availability",
+	"RDS-EVENT-0006": "availability",
+	"RDS-EVENT-0022": "availability",
+	"RDS-EVENT-0031": "failure",
+	"RDS-EVENT-0035": "failure",
+	"RDS-EVENT-0036": "failure",
+	"RDS-EVENT-0058": "failure",
+}
+
+// rdsCategoryColors attachment color for each RDS event category, anything else is informational
+var rdsCategoryColors = map[string]string{
+	"failover":     "danger",
+	"failure":      "danger",
+	"low storage":  "danger",
+	"availability": "warning",
+	"maintenance":  "warning",
+}
+
+// rdsCategorySeverities the severity each RDS event category is routed with, anything else is informational
+var rdsCategorySeverities = map[string]Severity{
+	"failover":     SeverityHigh,
+	"failure":      SeverityCritical,
+	"low storage":  SeverityHigh,
+	"availability": SeverityWarning,
+	"maintenance":  SeverityWarning,
+}
+
+// RDSEvent the message of an RDS event subscription
+type RDSEvent struct {
+	EventSource    string `json:"Event Source"`
+	EventTime      string `json:"Event Time"`
+	IdentifierLink string `json:"Identifier Link"`
+	SourceID       string `json:"Source ID"`
+	SourceARN      string `json:"Source ARN"`
+	EventID        string `json:"Event ID"`
+	EventMessage   string `json:"Event Message"`
+}
+
+// rdsMessage renders an RDS event subscription message with the DB identifier and the category of the event.  It's
+// routed by ROUTES like an alarm named after the DB identifier would be, with the category's severity.
+func rdsMessage(sns events.SNSEntity) (*OtherMessage, error) {
+	event := RDSEvent{}
+	if err := json.Unmarshal([]byte(sns.Message), &event); err != nil {
+		return nil, err
+	}
+
+	// The event ID is a link to the documentation ending with the ID itself
+	eventID := event.EventID[strings.LastIndex(event.EventID, "#")+1:]
+	category, ok := rdsEventCategories[eventID]
+	if !ok {
+		category = "notification"
+	}
+	color, ok := rdsCategoryColors[category]
+	if !ok {
+		color = "#439FE0"
+	}
+
+	severity, ok := rdsCategorySeverities[category]
+	if !ok {
+		severity = SeverityInfo
+	}
+
+	text := event.EventMessage
+	if event.IdentifierLink != "" {
+		text = fmt.Sprintf("%s\n<%s|Console>", text, event.IdentifierLink)
+	}
+	return &OtherMessage{Channel: rdsChannel(event, severity), Attachment: slack.Attachment{
+		Color: color,
+		Title: fmt.Sprintf(":floppy_disk: RDS %s %s: %s", event.EventSource, event.SourceID, category),
+		Text:  text,
+		Ts:    time.Now().Unix(),
+		AttachmentField: []slack.AttachmentField{
+			{Title: "Identifier", Value: event.SourceID, Short: true},
+			{Title: "Category", Value: category, Short: true},
+			{Title: "Event", Value: eventID, Short: true},
+			{Title: "Event Time", Value: event.EventTime, Short: true},
+		},
+	}}, nil
+}
+
+// rdsChannel the channel ROUTES and the severity overrides send the RDS event to, empty for the monitor channel.  Other
+// messages are only posted to the notifier's own workspace, so a route to another one of the SLACK_WORKSPACES is left
+// to the monitor channel.
+func rdsChannel(event RDSEvent, severity Severity) string {
+	routed := CloudWatchAlarmEvent{AlarmName: event.SourceID, AlarmARN: event.SourceARN}
+	routed.AWSAccountID = routed.Source().AccountID
+	channel := eventChannel(routed, severity)
+	if workspace, _ := splitChannel(channel); workspace != "" {
+		return ""
+	}
+	return channel
+}
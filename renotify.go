@@ -0,0 +1,99 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// renotifyStillFiring re-notifies every unacknowledged alarm that has been in ALARM for another renotify interval
+// since it was last notified, getting louder with every reminder.  Alarms that were never notified, or are suppressed
+// now, aren't re-notified.
+func renotifyStillFiring(ctx context.Context) error {
+	if renotifyInterval <= 0 {
+		return nil
+	}
+
+	alarmStates, err := stateStore.AlarmStates(ctx)
+	if err != nil {
+		return err
+	}
+	suppressions, err := currentSuppressions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, alarmState := range alarmStates {
+		if alarmState.State != "ALARM" || alarmState.Pending || alarmState.AcknowledgedBy != "" {
+			continue
+		}
+		if time.Since(alarmState.LastNotified) < renotifyInterval {
+			continue
+		}
+		if reason := reminderSuppressed(ctx, alarmState, suppressions); reason != "" {
+			slog.InfoContext(ctx, "Not re-notifying", "alarm_arn", alarmState.AlarmARN, "reason", reason)
+			continue
+		}
+
+		alarmState.Renotifications++
+		renotify(ctx, alarmState)
+		alarmState.LastNotified = time.Now()
+		if err := stateStore.PutAlarmState(ctx, alarmState); err != nil {
+			slog.ErrorContext(ctx, "Saving the alarm state failed", "alarm_arn", alarmState.AlarmARN, "error", err)
+		}
+	}
+	return nil
+}
+
+// renotify bumps the alarm's thread, or re-sends the alarm when there isn't one, with urgency growing each reminder
+func renotify(ctx context.Context, alarmState AlarmState) {
+	urgency := ":warning:"
+	if alarmState.Renotifications > 1 {
+		urgency = strings.Repeat(":rotating_light:", alarmState.Renotifications-1)
+	}
+	text := fmt.Sprintf("%s *%s* is still in ALARM after %s (reminder %d)", urgency, alarmState.AlarmName,
+		time.Since(alarmState.TransitionTime).Round(time.Minute), alarmState.Renotifications)
+	if alarmState.Renotifications > 2 && escalationMentions != "" {
+		text = escalationMentions + " " + text
+	}
+	slog.InfoContext(ctx, "Re-notifying", "alarm_arn", alarmState.AlarmARN, "alarm_name", alarmState.AlarmName, "reminder", alarmState.Renotifications)
+	ctx = withAlarms(ctx, alarmState.AlarmARN)
+
+	if currentSlackBotToken() != "" && alarmState.MessageTs != "" {
+		resp, err := postMessage(ctx, SlackMessage{
+			Channel:        alarmState.Channel,
+			ThreadTs:       alarmState.MessageTs,
+			ReplyBroadcast: true,
+			Text:           text,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Posting the reminder failed", "destination", "slack:"+alarmState.Channel, "error", err)
+		} else {
+			slog.InfoContext(ctx, "Posted the reminder", "destination", "slack:"+alarmState.Channel, "ts", resp.Ts)
+		}
+		return
+	}
+
+	n := newNotification(ctx, events.SNSEntity{Subject: alarmState.Subject}, alarmState.Event)
+	n.slackAttachment.Text = text + "\n" + n.slackAttachment.Text
+	postAttachments(ctx, ownChannel(alarmState.Event, alarmSeverity(alarmState.Event)), []slack.Attachment{n.slackAttachment})
+}
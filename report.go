@@ -0,0 +1,128 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+const (
+	// reportPeriod how far back the noisy alarm report looks
+	reportPeriod = 7 * 24 * time.Hour
+	// reportSize how many alarms the noisy alarm report lists
+	reportSize = 10
+)
+
+// alarmNoise how noisy an alarm was over the report period
+type alarmNoise struct {
+	name        string
+	transitions int
+	alarms      int
+	flaps       int
+	recoveries  []time.Duration
+}
+
+// mttr the mean of the recoveries, zero if there weren't any
+func (noise alarmNoise) mttr() time.Duration {
+	if len(noise.recoveries) == 0 {
+		return 0
+	}
+	total := time.Duration(0)
+	for _, recovery := range noise.recoveries {
+		total += recovery
+	}
+	return total / time.Duration(len(noise.recoveries))
+}
+
+// noisyAlarmReport posts the alarms that changed state the most over the past week along with how often they flapped
+// and their MTTR, to encourage tuning or deleting them
+func noisyAlarmReport(ctx context.Context) error {
+	alarmStates, err := stateStore.AlarmStates(ctx)
+	if err != nil {
+		return err
+	}
+
+	since := time.Now().Add(-reportPeriod)
+	noisy := []alarmNoise{}
+	for _, alarmState := range alarmStates {
+		transitions, err := stateStore.Transitions(ctx, alarmState.AlarmARN, since)
+		if err != nil {
+			return err
+		}
+		if len(transitions) == 0 {
+			continue
+		}
+
+		noise := alarmNoise{name: alarmState.AlarmName, transitions: len(transitions)}
+		for i, transition := range transitions {
+			if transition.State == "ALARM" {
+				noise.alarms++
+			}
+			if i == 0 {
+				continue
+			}
+			previous := transitions[i-1]
+			// A change back within the flap window of the last one is counted as a flap
+			if transition.TransitionTime.Sub(previous.TransitionTime) < flapWindow {
+				noise.flaps++
+			}
+			if previous.State == "ALARM" && transition.State == "OK" {
+				noise.recoveries = append(noise.recoveries, transition.TransitionTime.Sub(previous.TransitionTime))
+			}
+		}
+		noisy = append(noisy, noise)
+	}
+
+	if len(noisy) == 0 {
+		slog.InfoContext(ctx, "No alarms changed state in the past week")
+		return nil
+	}
+
+	sort.Slice(noisy, func(i, j int) bool {
+		if noisy[i].transitions != noisy[j].transitions {
+			return noisy[i].transitions > noisy[j].transitions
+		}
+		return noisy[i].name < noisy[j].name
+	})
+	if len(noisy) > reportSize {
+		noisy = noisy[:reportSize]
+	}
+
+	lines := []string{}
+	for i, noise := range noisy {
+		mttr := "n/a"
+		if len(noise.recoveries) != 0 {
+			mttr = noise.mttr().Round(time.Second).String()
+		}
+		lines = append(lines, fmt.Sprintf("%d. *%s* %d state changes, %d ALARM, %d flaps, MTTR %s",
+			i+1, noise.name, noise.transitions, noise.alarms, noise.flaps, mttr))
+	}
+
+	postAttachments(ctx, slackMonitorChannel, []slack.Attachment{{
+		Color:  "#439FE0",
+		Title:  fmt.Sprintf(":loudspeaker: Top %d noisiest alarms this week", len(noisy)),
+		Text:   strings.Join(lines, "\n"),
+		Footer: "Consider tuning the thresholds or evaluation periods of these alarms, or deleting them",
+		Ts:     time.Now().Unix(),
+	}})
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// What happened to each record in an invocation
+const (
+	outcomeSent       = "sent"
+	outcomeSuppressed = "suppressed"
+	outcomeDuplicate  = "duplicate"
+	outcomeSkipped    = "skipped"
+	outcomeDropped    = "dropped"
+	outcomeFailed     = "failed"
+)
+
+// recordResults the outcome of each record in an invocation, so one record failing is reported as that record rather
+// than the batch.  Records processed concurrently can record their outcomes at the same time.
+type recordResults struct {
+	mutex    sync.Mutex
+	ids      []string
+	outcomes map[string]string
+	errors   map[string]error
+}
+
+// newRecordResults results with no records yet
+func newRecordResults() *recordResults {
+	return &recordResults{outcomes: map[string]string{}, errors: map[string]error{}}
+}
+
+// record sets the outcome of the record, replacing any it had
+func (results *recordResults) record(id string, outcome string, err error) {
+	results.mutex.Lock()
+	defer results.mutex.Unlock()
+	if _, ok := results.outcomes[id]; !ok {
+		results.ids = append(results.ids, id)
+	}
+	results.outcomes[id] = outcome
+	if err != nil {
+		results.errors[id] = err
+	} else {
+		delete(results.errors, id)
+	}
+}
+
+// recordNotifications sets the outcome of the records the notifications were for
+func (results *recordResults) recordNotifications(notifications []*notification) {
+	for _, n := range notifications {
+		switch {
+		case n.failed != nil:
+			results.record(n.messageID, outcomeFailed, n.failed)
+		case n.suppressed != "":
+			results.record(n.messageID, outcomeSuppressed, nil)
+		default:
+			results.record(n.messageID, outcomeSent, nil)
+		}
+	}
+}
+
+// summarize logs how many records had each outcome and which failed
+func (results *recordResults) summarize(ctx context.Context) {
+	results.mutex.Lock()
+	defer results.mutex.Unlock()
+	counts := map[string]int{}
+	failed := []string{}
+	for _, id := range results.ids {
+		counts[results.outcomes[id]]++
+		if results.outcomes[id] == outcomeFailed {
+			failed = append(failed, id)
+		}
+	}
+	slog.InfoContext(ctx, "Processed records", "records", len(results.ids), "outcomes", counts, "failed_message_ids", failed)
+}
+
+// err the partial failure of the records that failed, nil when none did
+func (results *recordResults) err() error {
+	results.mutex.Lock()
+	defer results.mutex.Unlock()
+	if len(results.errors) == 0 {
+		return nil
+	}
+	failures := []string{}
+	for _, id := range results.ids {
+		if err, ok := results.errors[id]; ok {
+			failures = append(failures, fmt.Sprintf("%s: %s", id, err))
+		}
+	}
+	return fmt.Errorf("%d of %d records failed: %s", len(failures), len(results.ids), strings.Join(failures, "; "))
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryMaxBackoff the longest a retry waits however many attempts came before it
+const retryMaxBackoff = 10 * time.Second
+
+// retryTransport retries requests that fail with a network error or a server error, waiting an exponentially growing
+// backoff with a random fraction of it taken off as jitter between attempts.  Rate limited requests wait for as long as
+// their Retry-After says instead, so long as that's before the deadline of the send.  Every destination sends through
+// it so a transient failure doesn't drop the notification.
+type retryTransport struct {
+	next     http.RoundTripper
+	attempts int
+	backoff  time.Duration
+	jitter   float64
+	timeout  time.Duration
+}
+
+// attemptBody cancels the attempt's context once its response has been read, as the attempt's timeout has to cover
+// reading the body too
+type attemptBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer
+func (body attemptBody) Close() error {
+	defer body.cancel()
+	return body.ReadCloser.Close()
+}
+
+// roundTrip makes one attempt at the request, limited to the transport's timeout
+func (transport retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if transport.timeout <= 0 {
+		return transport.next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), transport.timeout)
+	resp, err := transport.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = attemptBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (transport retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempt := req
+	for i := 1; ; i++ {
+		resp, err := transport.roundTrip(attempt)
+		if !retryable(resp, err) || i >= transport.attempts || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+
+		delay := transport.delay(i)
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			// Rate limited, so wait as long as asked
+			if retryAfter, ok := retryAfter(resp); ok {
+				delay = retryAfter
+			}
+		}
+		// A retry that can't start before the deadline would only be cut off, so the failure is returned while there's
+		// still time to dead letter it
+		if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return resp, err
+		}
+		if err != nil {
+			slog.WarnContext(req.Context(), "Retrying", "method", req.Method, "host", req.URL.Host, "delay", delay.String(), "error", err)
+		} else {
+			slog.WarnContext(req.Context(), "Retrying", "method", req.Method, "host", req.URL.Host, "delay", delay.String(), "status", resp.Status)
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+
+		// The body was read by the last attempt so each retry gets a fresh copy of it
+		attempt = new(http.Request)
+		*attempt = *req
+		if req.GetBody != nil {
+			if attempt.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// delay how long to wait before the retry following the attempt
+func (transport retryTransport) delay(attempt int) time.Duration {
+	delay := transport.backoff << uint(attempt-1)
+	if delay > retryMaxBackoff || delay <= 0 {
+		delay = retryMaxBackoff
+	}
+	return delay - time.Duration(transport.jitter*rand.Float64()*float64(delay))
+}
+
+// retryAfter how long the Retry-After header of the response asks to wait, given in seconds or as a date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+	return 0, false
+}
+
+// retryable whether the request failed in a way another attempt might not
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
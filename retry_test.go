@@ -0,0 +1,125 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripperFunc a stub transport answering with the function
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		attempt int
+		jitter  float64
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"first", 1, 0, 200 * time.Millisecond, 200 * time.Millisecond},
+		{"doubled", 3, 0, 800 * time.Millisecond, 800 * time.Millisecond},
+		{"capped", 10, 0, retryMaxBackoff, retryMaxBackoff},
+		{"overflowed", 70, 0, retryMaxBackoff, retryMaxBackoff},
+		{"jittered", 2, 0.5, 200 * time.Millisecond, 400 * time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := retryTransport{backoff: 200 * time.Millisecond, jitter: test.jitter}
+			for i := 0; i < 100; i++ {
+				if delay := transport.delay(test.attempt); delay < test.min || delay > test.max {
+					t.Fatalf("delay(%d) = %s, want between %s and %s", test.attempt, delay, test.min, test.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		min        time.Duration
+		max        time.Duration
+		ok         bool
+	}{
+		{"missing", "", 0, 0, false},
+		{"seconds", "30", 30 * time.Second, 30 * time.Second, true},
+		{"date", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 58 * time.Second, time.Minute, true},
+		{"garbage", "soon", 0, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if test.retryAfter != "" {
+				resp.Header.Set("Retry-After", test.retryAfter)
+			}
+			delay, ok := retryAfter(resp)
+			if ok != test.ok || delay < test.min || delay > test.max {
+				t.Errorf("retryAfter(%q) = %s, %t, want between %s and %s, %t", test.retryAfter, delay, ok, test.min, test.max, test.ok)
+			}
+		})
+	}
+}
+
+func TestRetryRateLimited(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		attempts   int
+		status     int
+	}{
+		{"waits as asked", "0", 2, http.StatusOK},
+		{"past the deadline", "60", 1, http.StatusTooManyRequests},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			transport := retryTransport{
+				next: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					attempts++
+					resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+					if attempts == 1 {
+						resp.StatusCode = http.StatusTooManyRequests
+						resp.Header.Set("Retry-After", test.retryAfter)
+					}
+					return resp, nil
+				}),
+				attempts: 3,
+				backoff:  time.Hour,
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "https://hooks.slack.com/services/T/B/X", nil)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if attempts != test.attempts || resp.StatusCode != test.status {
+				t.Errorf("RoundTrip() made %d attempts and got %d, want %d attempts and %d", attempts, resp.StatusCode, test.attempts, test.status)
+			}
+		})
+	}
+}
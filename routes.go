@@ -0,0 +1,125 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Route sends the alarms it matches to its channel rather than SLACK_MONITOR_CHANNEL, rendered with its template.
+// Alarm is a regexp matched against the alarm name, and MinSeverity the least urgent severity it matches.  Channel can
+// be workspace:channel for a channel in one of the SLACK_WORKSPACES.  Anything left empty matches every alarm.
+type Route struct {
+	Alarm       string `json:"alarm,omitempty"`
+	Account     string `json:"account,omitempty"`
+	Region      string `json:"region,omitempty"`
+	MinSeverity string `json:"min_severity,omitempty"`
+	Channel     string `json:"channel,omitempty"`
+	Template    string `json:"template,omitempty"`
+
+	alarm       *regexp.Regexp
+	minSeverity Severity
+}
+
+// parseRoutes parses the ROUTES setting, a JSON array of routes tried in order
+func parseRoutes(value string) ([]Route, error) {
+	parsed := []Route{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, err
+	}
+	for i := range parsed {
+		route := &parsed[i]
+		if route.Alarm != "" {
+			pattern, err := regexp.Compile(route.Alarm)
+			if err != nil {
+				return nil, fmt.Errorf("route %d: %s", i, err)
+			}
+			route.alarm = pattern
+		}
+		if route.MinSeverity != "" {
+			severity, err := ParseSeverity(route.MinSeverity)
+			if err != nil {
+				return nil, fmt.Errorf("route %d: %s", i, err)
+			}
+			route.minSeverity = severity
+		}
+		if route.Template != "" {
+			if _, ok := templates[route.Template]; !ok {
+				return nil, fmt.Errorf("route %d: unknown template %q", i, route.Template)
+			}
+		}
+		if err := validChannel(route.Channel); err != nil {
+			return nil, fmt.Errorf("route %d: %s", i, err)
+		}
+	}
+	return parsed, nil
+}
+
+// matches whether the route applies to the alarm
+func (route Route) matches(cloudWatchAlarmEvent CloudWatchAlarmEvent, severity Severity) bool {
+	if route.alarm != nil && !route.alarm.MatchString(cloudWatchAlarmEvent.AlarmName) {
+		return false
+	}
+	if route.Account != "" && route.Account != cloudWatchAlarmEvent.AWSAccountID {
+		return false
+	}
+	// Events carry the region's display name once normalized, routes can give either it or the code
+	if route.Region != "" && route.Region != cloudWatchAlarmEvent.Source().Region && route.Region != cloudWatchAlarmEvent.Region {
+		return false
+	}
+	// Lower severities are more urgent
+	return route.minSeverity == 0 || severity <= route.minSeverity
+}
+
+// route the first route matching the alarm, empty when none do
+func route(cloudWatchAlarmEvent CloudWatchAlarmEvent, severity Severity) Route {
+	for _, route := range routes {
+		if route.matches(cloudWatchAlarmEvent, severity) {
+			return route
+		}
+	}
+	return Route{}
+}
+
+// alarmChannel the channel the notification is posted to
+func alarmChannel(n *notification) string {
+	return eventChannel(n.cloudWatchAlarmEvent, n.severity)
+}
+
+// ownChannel the channel the alarm is routed to in the notifier's own workspace, for posts that can't go to another one
+// of the SLACK_WORKSPACES, which are left to the monitor channel
+func ownChannel(cloudWatchAlarmEvent CloudWatchAlarmEvent, severity Severity) string {
+	channel := eventChannel(cloudWatchAlarmEvent, severity)
+	if workspace, _ := splitChannel(channel); workspace != "" {
+		return slackMonitorChannel
+	}
+	return channel
+}
+
+// eventChannel the channel an alarm of the severity is posted to
+func eventChannel(cloudWatchAlarmEvent CloudWatchAlarmEvent, severity Severity) string {
+	if channel := route(cloudWatchAlarmEvent, severity).Channel; channel != "" {
+		return channel
+	}
+	if channel := accountOverride(cloudWatchAlarmEvent).Channel; channel != "" {
+		return channel
+	}
+	if channel := severityOverride(severity).Channel; channel != "" {
+		return channel
+	}
+	return slackMonitorChannel
+}
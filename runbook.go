@@ -0,0 +1,99 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	runbookSnippetLimit = 1000
+	// Don't read more than this much of a runbook looking for the first section
+	runbookReadLimit = 64 * 1024
+)
+
+// runbookSnippet fetches a markdown runbook from S3 (s3://bucket/key) or HTTP(S) (*.md) and returns its first section.
+// Runbooks anywhere else aren't fetched and return an empty snippet.
+func runbookSnippet(ctx context.Context, link string) (string, error) {
+	runbook, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+
+	var body io.ReadCloser
+	switch {
+	case runbook.Scheme == "s3":
+		client, err := s3Service()
+		if err != nil {
+			return "", err
+		}
+		output, err := client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(runbook.Host),
+			Key:    aws.String(strings.TrimPrefix(runbook.Path, "/")),
+		})
+		if err != nil {
+			return "", err
+		}
+		body = output.Body
+	case (runbook.Scheme == "https" || runbook.Scheme == "http") && strings.HasSuffix(strings.ToLower(runbook.Path), ".md"):
+		req, err := http.NewRequest(http.MethodGet, link, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := httpClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return "", fmt.Errorf("runbook %s returned %s", link, resp.Status)
+		}
+		body = resp.Body
+	default:
+		return "", nil
+	}
+	defer body.Close()
+
+	snippet, _ := truncate(firstSection(io.LimitReader(body, runbookReadLimit)), runbookSnippetLimit)
+	return snippet, nil
+}
+
+// firstSection the content under the first markdown heading that has any, e.g. skipping a title heading that is
+// immediately followed by a subheading
+func firstSection(markdown io.Reader) string {
+	lines := []string{}
+	scanner := bufio.NewScanner(markdown)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			if strings.TrimSpace(strings.Join(lines, "")) != "" {
+				break
+			}
+			lines = lines[:0]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// byteSize the size in the largest unit it's at least one of
+func byteSize(size int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+// isS3Message detects S3 event notifications, including the test event S3 sends when notifications are configured
+func isS3Message(sns events.SNSEntity) bool {
+	message := struct {
+		Service string `json:"Service"`
+		Records []struct {
+			EventSource string `json:"eventSource"`
+		} `json:"Records"`
+	}{}
+	if err := json.Unmarshal([]byte(sns.Message), &message); err != nil {
+		return false
+	}
+	return message.Service == "Amazon S3" || (len(message.Records) != 0 && message.Records[0].EventSource == "aws:s3")
+}
+
+// s3Message renders an S3 event notification as a line per object with its event type and size.  The test event is
+// dropped.
+func s3Message(sns events.SNSEntity) (*OtherMessage, error) {
+	test := struct {
+		Event string `json:"Event"`
+	}{}
+	if err := json.Unmarshal([]byte(sns.Message), &test); err == nil && test.Event == "s3:TestEvent" {
+		return nil, nil
+	}
+
+	event := events.S3Event{}
+	if err := json.Unmarshal([]byte(sns.Message), &event); err != nil {
+		return nil, err
+	}
+
+	buckets := []string{}
+	lines := []string{}
+	for _, record := range event.Records {
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			key = record.S3.Object.Key
+		}
+		line := fmt.Sprintf("`%s` s3://%s/%s", record.EventName, record.S3.Bucket.Name, key)
+		if strings.HasPrefix(record.EventName, "ObjectCreated") {
+			line += fmt.Sprintf(" (%s)", byteSize(record.S3.Object.Size))
+		}
+		lines = append(lines, line)
+		buckets = appendUnique(buckets, record.S3.Bucket.Name)
+	}
+	text, _ := truncate(strings.Join(lines, "\n"), slackTextLimit)
+
+	return &OtherMessage{Attachment: slack.Attachment{
+		Color: "#439FE0",
+		Title: fmt.Sprintf(":bucket: S3 activity in %s", strings.Join(buckets, ", ")),
+		Text:  text,
+		Ts:    time.Now().Unix(),
+		AttachmentField: []slack.AttachmentField{
+			{Title: "Region", Value: event.Records[0].AWSRegion, Short: true},
+			{Title: "Event Time", Value: event.Records[0].EventTime.UTC().Format(time.RFC1123), Short: true},
+		},
+	}}, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+
+	"github.com/xeipuuv/gojsonschema"
+	"sigs.k8s.io/yaml"
+)
+
+// configSchema the JSON Schema config files are validated against, published alongside the code as config.schema.json
+//
+//go:embed config.schema.json
+var configSchema string
+
+// validating whether the binary was run as the validate command rather than by the Lambda runtime
+func validating() bool {
+	return len(os.Args) > 1 && os.Args[1] == "validate"
+}
+
+// validateCommand checks config files against the schema, then parses the settings in them the way the notifier does
+// to catch what the schema can't, like a route's regexp not compiling.  Every problem is printed and the exit code is
+// 1 when there were any, so it can be run in CI before deploying, e.g. notifier validate config.yaml.
+func validateCommand(paths []string) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: notifier validate <config file>...")
+		return 2
+	}
+
+	status := 0
+	for _, path := range paths {
+		problems, err := validateConfigFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			status = 1
+			continue
+		}
+		if len(problems) == 0 {
+			fmt.Printf("%s: valid\n", path)
+			continue
+		}
+		for _, problem := range problems {
+			fmt.Printf("%s: %s\n", path, problem)
+		}
+		status = 1
+	}
+	return status
+}
+
+// validateConfigFile the problems with the YAML or JSON config file at the path, an error when it can't be read at all
+func validateConfigFile(path string) ([]string, error) {
+	document, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := yaml.YAMLToJSON(document)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewStringLoader(configSchema), gojsonschema.NewBytesLoader(converted))
+	if err != nil {
+		return nil, err
+	}
+	problems := []string{}
+	for _, resultErr := range result.Errors() {
+		problems = append(problems, fmt.Sprintf("%s: %s", resultErr.Field(), resultErr.Description()))
+	}
+	if !result.Valid() {
+		return problems, nil
+	}
+
+	// Only once the shape is right is it worth parsing the settings
+	values, err := parseConfigValues(converted)
+	if err != nil {
+		return nil, err
+	}
+	setConfigValues(configSourceFile, values)
+	takeConfigProblems(true)
+	applyPreset()
+	configure()
+	return append(problems, takeConfigProblems(false)...), nil
+}
@@ -0,0 +1,297 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// secret a setting that shouldn't sit in plaintext in the function's configuration.  It's read from its env var, unless
+// the env var with _PARAMETER appended names an SSM SecureString parameter to read it from instead, or with _SECRET
+// appended names a Secrets Manager secret.  A secret holding a JSON object has the value under the env var's name
+// taken from it, so one secret can hold all of them.
+type secret struct {
+	name  string
+	apply func(value string)
+}
+
+// secrets the secrets along with how each one is put in place, rebuilding whatever client was made from the old value
+var secrets = []secret{
+	{"SLACK_WEBHOOK", func(value string) {
+		clientsMutex.Lock()
+		defer clientsMutex.Unlock()
+		slackWebhook, slackClient = value, nil
+	}},
+	{"SLACK_FAILOVER_WEBHOOK", func(value string) {
+		client := webhookSecretClient("SLACK_FAILOVER_WEBHOOK", value)
+		appliedSecretsMutex.Lock()
+		defer appliedSecretsMutex.Unlock()
+		slackFailoverClient = client
+	}},
+	{"ESCALATION_WEBHOOK", func(value string) {
+		client := webhookSecretClient("ESCALATION_WEBHOOK", value)
+		appliedSecretsMutex.Lock()
+		defer appliedSecretsMutex.Unlock()
+		escalationClient = client
+	}},
+	{"SLACK_BOT_TOKEN", func(value string) {
+		appliedSecretsMutex.Lock()
+		defer appliedSecretsMutex.Unlock()
+		slackBotToken = value
+	}},
+	{"SLACK_SIGNING_SECRET", func(value string) {
+		appliedSecretsMutex.Lock()
+		defer appliedSecretsMutex.Unlock()
+		slackSigningSecret = value
+	}},
+	{"TEAMS_WEBHOOK", func(value string) {
+		appliedSecretsMutex.Lock()
+		defer appliedSecretsMutex.Unlock()
+		teamsWebhook = value
+	}},
+}
+
+// appliedSecretsMutex guards the secrets once they're put in place, which happens again while other records are being
+// sent when they're refreshed or a destination rejected its credentials.  They're read through the current* functions.
+var appliedSecretsMutex sync.RWMutex
+
+// currentSlackWebhook SLACK_WEBHOOK
+func currentSlackWebhook() string {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	return slackWebhook
+}
+
+// currentSlackBotToken SLACK_BOT_TOKEN
+func currentSlackBotToken() string {
+	appliedSecretsMutex.RLock()
+	defer appliedSecretsMutex.RUnlock()
+	return slackBotToken
+}
+
+// currentSlackSigningSecret SLACK_SIGNING_SECRET
+func currentSlackSigningSecret() string {
+	appliedSecretsMutex.RLock()
+	defer appliedSecretsMutex.RUnlock()
+	return slackSigningSecret
+}
+
+// currentTeamsWebhook TEAMS_WEBHOOK
+func currentTeamsWebhook() string {
+	appliedSecretsMutex.RLock()
+	defer appliedSecretsMutex.RUnlock()
+	return teamsWebhook
+}
+
+// currentFailoverClient the client for SLACK_FAILOVER_WEBHOOK, nil when it isn't set
+func currentFailoverClient() *webhookClient {
+	appliedSecretsMutex.RLock()
+	defer appliedSecretsMutex.RUnlock()
+	return slackFailoverClient
+}
+
+// currentEscalationClient the client for ESCALATION_WEBHOOK, nil when it isn't set
+func currentEscalationClient() *webhookClient {
+	appliedSecretsMutex.RLock()
+	defer appliedSecretsMutex.RUnlock()
+	return escalationClient
+}
+
+// secretsReloadInterval how often a destination rejecting its credentials can have the secrets fetched again
+const secretsReloadInterval = time.Minute
+
+var (
+	secretValues    = map[string]string{}
+	secretChanges   int
+	secretsLoaded   time.Time
+	secretsReloaded time.Time
+	secretsMutex    sync.Mutex
+)
+
+// loadSecrets puts the secrets in place, fetching the ones kept in SSM again once SECRETS_REFRESH is up so a rotated
+// webhook or token is picked up by warm functions without a redeploy.  A parameter that can't be fetched keeps the
+// value it had.
+func loadSecrets(ctx context.Context) {
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	if !secretsLoaded.IsZero() && time.Since(secretsLoaded) < secretsRefresh {
+		return
+	}
+	secretsLoaded = time.Now()
+
+	for _, secret := range secrets {
+		value, ok := secretValues[secret.name]
+		if !ok {
+			value = getenv(secret.name)
+		}
+		if parameter := getenv(secret.name + "_PARAMETER"); parameter != "" {
+			fetched, err := secretParameter(ctx, parameter)
+			if err != nil {
+				slog.WarnContext(ctx, "Loading the secret failed", "setting", secret.name+"_PARAMETER", "error", err)
+			} else {
+				value = fetched
+			}
+		} else if id := getenv(secret.name + "_SECRET"); id != "" {
+			fetched, err := secretsManagerValue(ctx, id, secret.name)
+			if err != nil {
+				slog.WarnContext(ctx, "Loading the secret failed", "setting", secret.name+"_SECRET", "error", err)
+			} else {
+				value = fetched
+			}
+		}
+		if current, ok := secretValues[secret.name]; ok && current == value {
+			continue
+		}
+		if _, ok := secretValues[secret.name]; ok {
+			secretChanges++
+		}
+		secretValues[secret.name] = value
+		secret.apply(value)
+	}
+}
+
+// reloadSecrets fetches the secrets again after a destination rejected its credentials, returning whether any of them
+// changed so the send can be tried again with the rotated ones.  It only fetches once per secretsReloadInterval, so a
+// credential that's simply wrong doesn't have every send fetching them.
+func reloadSecrets(ctx context.Context) bool {
+	secretsMutex.Lock()
+	if time.Since(secretsReloaded) < secretsReloadInterval {
+		secretsMutex.Unlock()
+		return false
+	}
+	secretsReloaded = time.Now()
+	secretsLoaded = time.Time{}
+	changes := secretChanges
+	secretsMutex.Unlock()
+
+	loadSecrets(bypassSecretsExtension(ctx))
+
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	if secretChanges == changes {
+		return false
+	}
+	slog.InfoContext(ctx, "Secrets were rotated, retrying with the new ones")
+	return true
+}
+
+// secretParameter the decrypted value of the SSM parameter
+func secretParameter(ctx context.Context, name string) (string, error) {
+	return getParameter(ctx, name, true)
+}
+
+// getParameter the value of the SSM parameter, through the Parameters and Secrets extension when the function has it.
+// A missing parameter is the SDK's ParameterNotFound error either way, anything else going wrong with the extension
+// falls back to calling SSM.
+func getParameter(ctx context.Context, name string, decrypt bool) (string, error) {
+	if useSecretsExtension(ctx) {
+		value, err := extensionParameter(ctx, name, decrypt)
+		if aerr, ok := err.(awserr.Error); err == nil || (ok && aerr.Code() == ssm.ErrCodeParameterNotFound) {
+			return value, err
+		}
+		slog.WarnContext(ctx, "Parameters and Secrets extension failed, falling back to SSM", "parameter", name, "error", err)
+	}
+
+	client, err := ssmService()
+	if err != nil {
+		return "", err
+	}
+	output, err := client.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(decrypt),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.Parameter.Value), nil
+}
+
+// secretsManagerValue the value of the Secrets Manager secret, or the named key of it when it's a JSON object and a
+// name is given
+func secretsManagerValue(ctx context.Context, id string, name string) (string, error) {
+	value, err := secretString(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	keys := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(value), &keys); err != nil || name == "" {
+		return value, nil
+	}
+	key, ok := keys[name].(string)
+	if !ok {
+		return "", fmt.Errorf("secret %s has no %s key", id, name)
+	}
+	return key, nil
+}
+
+// secretString the Secrets Manager secret, through the Parameters and Secrets extension when the function has it
+func secretString(ctx context.Context, id string) (string, error) {
+	if useSecretsExtension(ctx) {
+		value, err := extensionSecret(ctx, id)
+		if err == nil {
+			return value, nil
+		}
+		slog.WarnContext(ctx, "Parameters and Secrets extension failed, falling back to Secrets Manager", "secret_id", id, "error", err)
+	}
+
+	client, err := secretsManagerService()
+	if err != nil {
+		return "", err
+	}
+	output, err := client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.SecretString), nil
+}
+
+// rejectedCredentials whether the webhook's response says its URL is no longer valid, as it is once rotated
+func rejectedCredentials(resp *http.Response) bool {
+	if resp == nil {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// webhookSecretClient the client for the webhook, nil when it isn't set or isn't valid.  It's called again whenever the
+// secrets are refreshed, so an invalid one is only logged here and left to validateConfig to report.
+func webhookSecretClient(name string, webhook string) *webhookClient {
+	if webhook == "" {
+		return nil
+	}
+	if err := validWebhook(name, webhook); err != nil {
+		slog.Warn("Invalid webhook", "setting", name, "error", err)
+		return nil
+	}
+	return &webhookClient{url: webhook}
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// secretsExtensionPath where the AWS Parameters and Secrets Lambda Extension is installed by its layer
+const secretsExtensionPath = "/opt/extensions/AWSParametersAndSecretsLambdaExtension"
+
+// secretsExtensionClient talks to the extension, which is local so it isn't sent through the retries and circuit
+// breaker the destinations are
+var secretsExtensionClient = http.Client{Timeout: 2 * time.Second}
+
+// secretsExtensionURL the extension's local endpoint, empty when the function doesn't have the extension's layer.  It
+// caches parameters and secrets itself, so high volume functions aren't calling SSM and Secrets Manager for every
+// invocation.  Its cache lasts SSM_PARAMETER_STORE_TTL and SECRETS_MANAGER_TTL, 300 seconds unless they're set on the
+// function, on top of SECRETS_REFRESH, so a rotated webhook or token takes up to both to be picked up.
+func secretsExtensionURL() string {
+	port := getenv("PARAMETERS_SECRETS_EXTENSION_HTTP_PORT")
+	if port == "" {
+		if _, err := os.Stat(secretsExtensionPath); err != nil {
+			return ""
+		}
+		port = "2773"
+	}
+	return "http://localhost:" + port
+}
+
+// bypassSecretsExtensionContextKey marks a context whose parameters and secrets have to be fetched from SSM and Secrets
+// Manager themselves
+type bypassSecretsExtensionContextKey struct{}
+
+// bypassSecretsExtension the context with fetches skipping the extension, whose cache would hand back the value being
+// replaced after a destination rejected its credentials, a control message asked for a reload, or the kill switch was
+// flipped
+func bypassSecretsExtension(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassSecretsExtensionContextKey{}, true)
+}
+
+// useSecretsExtension whether fetches in the context go through the extension
+func useSecretsExtension(ctx context.Context) bool {
+	bypassed, _ := ctx.Value(bypassSecretsExtensionContextKey{}).(bool)
+	return !bypassed && secretsExtensionURL() != ""
+}
+
+// extensionGet fetches the path from the extension, decoding its JSON response
+func extensionGet(ctx context.Context, path string, query url.Values, response interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, secretsExtensionURL()+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Aws-Parameters-Secrets-Token", os.Getenv("AWS_SESSION_TOKEN"))
+	resp, err := secretsExtensionClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Passed on as the SDK's error so callers handle a missing parameter the same either way
+		if strings.Contains(string(body), ssm.ErrCodeParameterNotFound) {
+			return awserr.New(ssm.ErrCodeParameterNotFound, strings.TrimSpace(string(body)), nil)
+		}
+		return fmt.Errorf("extension returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, response)
+}
+
+// extensionParameter the value of the SSM parameter through the extension
+func extensionParameter(ctx context.Context, name string, decrypt bool) (string, error) {
+	response := struct {
+		Parameter struct {
+			Value string
+		}
+	}{}
+	query := url.Values{"name": {name}, "withDecryption": {fmt.Sprint(decrypt)}}
+	if err := extensionGet(ctx, "/systemsmanager/parameters/get", query, &response); err != nil {
+		return "", err
+	}
+	return response.Parameter.Value, nil
+}
+
+// extensionSecret the value of the Secrets Manager secret through the extension
+func extensionSecret(ctx context.Context, id string) (string, error) {
+	response := struct {
+		SecretString string
+	}{}
+	if err := extensionGet(ctx, "/secretsmanager/get", url.Values{"secretId": {id}}, &response); err != nil {
+		return "", err
+	}
+	return response.SecretString, nil
+}
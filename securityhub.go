@@ -0,0 +1,134 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// securityHubSeverityColors attachment color for each finding severity label
+var securityHubSeverityColors = map[string]string{
+	"CRITICAL":      "danger",
+	"HIGH":          "danger",
+	"MEDIUM":        "warning",
+	"LOW":           "#439FE0",
+	"INFORMATIONAL": "#439FE0",
+}
+
+// securityHubSeverityRank orders findings so the most severe one in an event is the one rendered
+var securityHubSeverityRank = map[string]int{
+	"CRITICAL":      4,
+	"HIGH":          3,
+	"MEDIUM":        2,
+	"LOW":           1,
+	"INFORMATIONAL": 0,
+}
+
+// SecurityHubFinding the parts of an AWS Security Finding Format finding that are rendered
+type SecurityHubFinding struct {
+	ID           string `json:"Id"`
+	Title        string `json:"Title"`
+	Description  string `json:"Description"`
+	AwsAccountID string `json:"AwsAccountId"`
+	ProductName  string `json:"ProductName"`
+	SourceURL    string `json:"SourceUrl"`
+	Severity     struct {
+		Label string `json:"Label"`
+	} `json:"Severity"`
+	Compliance struct {
+		Status string `json:"Status"`
+	} `json:"Compliance"`
+	Workflow struct {
+		Status string `json:"Status"`
+	} `json:"Workflow"`
+	Resources []struct {
+		Type   string `json:"Type"`
+		ID     string `json:"Id"`
+		Region string `json:"Region"`
+	} `json:"Resources"`
+}
+
+// securityHubMessage renders the most severe finding of a Security Hub findings event for SECURITY_CHANNEL
+func securityHubMessage(sns events.SNSEntity) (*OtherMessage, error) {
+	event := events.CloudWatchEvent{}
+	if err := json.Unmarshal([]byte(sns.Message), &event); err != nil {
+		return nil, err
+	}
+	detail := struct {
+		Findings []SecurityHubFinding `json:"findings"`
+	}{}
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		return nil, err
+	}
+	if len(detail.Findings) == 0 {
+		return nil, nil
+	}
+
+	finding := detail.Findings[0]
+	for _, other := range detail.Findings[1:] {
+		if securityHubSeverityRank[other.Severity.Label] > securityHubSeverityRank[finding.Severity.Label] {
+			finding = other
+		}
+	}
+
+	color, ok := securityHubSeverityColors[finding.Severity.Label]
+	if !ok {
+		color = "warning"
+	}
+	if finding.Compliance.Status == "PASSED" || finding.Workflow.Status == "RESOLVED" || finding.Workflow.Status == "SUPPRESSED" {
+		color = "good"
+	}
+
+	text, _ := truncate(finding.Description, slackTextLimit)
+	if finding.SourceURL != "" {
+		text = fmt.Sprintf("%s\n<%s|Remediation>", text, finding.SourceURL)
+	}
+	if len(detail.Findings) > 1 {
+		text = fmt.Sprintf("%s\n_%d more findings in this event_", text, len(detail.Findings)-1)
+	}
+
+	resources := []string{}
+	for _, resource := range finding.Resources {
+		resources = append(resources, fmt.Sprintf("%s %s (%s)", resource.Type, resource.ID, resource.Region))
+	}
+	fields := []slack.AttachmentField{
+		{Title: "Severity", Value: finding.Severity.Label, Short: true},
+		{Title: "Compliance", Value: finding.Compliance.Status, Short: true},
+		{Title: "Product", Value: finding.ProductName, Short: true},
+		{Title: "AccountID", Value: finding.AwsAccountID, Short: true},
+		{Title: "Workflow", Value: finding.Workflow.Status, Short: true},
+	}
+	if len(resources) > 0 {
+		value, _ := truncate(strings.Join(resources, "\n"), healthResourcesLimit)
+		fields = append(fields, slack.AttachmentField{Title: "Resources", Value: value, Short: false})
+	}
+
+	return &OtherMessage{
+		Channel: securityChannel,
+		Attachment: slack.Attachment{
+			Color:           color,
+			Title:           fmt.Sprintf(":shield: Security Hub %s: %s", finding.Severity.Label, finding.Title),
+			Text:            text,
+			Ts:              time.Now().Unix(),
+			AttachmentField: fields,
+		},
+	}, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// SelfTestReport how sending the self-test message through each destination went, "ok" or the error
+type SelfTestReport struct {
+	OK           bool              `json:"ok"`
+	Destinations map[string]string `json:"destinations"`
+}
+
+// selfTest sends a "configuration OK" message through every destination that's configured, for checking a deploy with
+// {"task": "test"}.  The message is an OK state so PagerDuty resolves rather than pages, under a made up alarm ARN
+// that's the same every time since PagerDuty rejects events without a dedup_key.
+func selfTest(ctx context.Context) (SelfTestReport, error) {
+	function := os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	region := os.Getenv("AWS_REGION")
+	account := functionSource(ctx).AccountID
+	now := time.Now().UTC()
+	message, err := json.Marshal(CloudWatchAlarmEvent{
+		AlarmName:        "configuration-test",
+		AlarmARN:         fmt.Sprintf("arn:aws:cloudwatch:%s:%s:alarm:configuration-test-%s", region, account, function),
+		AWSAccountID:     account,
+		AlarmDescription: "Test message sent by the notifier to check its destinations",
+		NewStateValue:    "OK",
+		NewStateReason:   fmt.Sprintf("Configuration OK, %s can deliver here (%s)", function, now.Format(time.RFC3339)),
+		StateChangeTime:  now.Format("2006-01-02T15:04:05.000-0700"),
+		Region:           region,
+		OldStateValue:    "OK",
+	})
+	if err != nil {
+		return SelfTestReport{}, err
+	}
+	sns := events.SNSEntity{
+		MessageID: "configuration-test-" + now.Format("20060102T150405"),
+		Subject:   `OK: "configuration-test"`,
+		Message:   string(message),
+	}
+	cloudWatchAlarmEvent, ok := parseAlarm(sns.Message)
+	if !ok {
+		return SelfTestReport{}, fmt.Errorf("test alarm didn't parse")
+	}
+	n := newNotification(ctx, sns, normalizeAlarm(cloudWatchAlarmEvent))
+	n.slackAttachment.Title = "Configuration OK"
+
+	tests := map[string]func(ctx context.Context) error{}
+	timeouts := map[string]time.Duration{"slack": slackTimeout, "teams": teamsTimeout}
+	if currentSlackWebhook() != "" || currentSlackBotToken() != "" {
+		tests["slack"] = func(ctx context.Context) error {
+			return postAttachments(ctx, alarmChannel(n), []slack.Attachment{n.slackAttachment})
+		}
+	}
+	for name, client := range map[string]*webhookClient{"slack-failover": currentFailoverClient(), "escalation": currentEscalationClient()} {
+		client := client
+		if client != nil {
+			tests[name] = func(ctx context.Context) error {
+				_, err := sendWebhook(ctx, client, slack.Payload{Attachments: []slack.Attachment{n.slackAttachment}})
+				return err
+			}
+		}
+	}
+	if currentTeamsWebhook() != "" {
+		tests["teams"] = func(ctx context.Context) error {
+			return sendTeamsCards(ctx, []*notification{n})
+		}
+	}
+	for _, destination := range destinations {
+		destination := destination
+		timeouts[destination.Name] = destination.sendTimeout()
+		tests[destination.Name] = func(ctx context.Context) error {
+			return destination.deliver(ctx, []*notification{n})
+		}
+	}
+
+	// Sent at the same time like a real dispatch, each with its own timeout
+	report := SelfTestReport{OK: true, Destinations: map[string]string{}}
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for name, test := range tests {
+		name, test := name, test
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			timeout, ok := timeouts[name]
+			if !ok {
+				timeout = destinationTimeout
+			}
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			result := "ok"
+			if err := test(ctx); err != nil {
+				result = err.Error()
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Destinations[name] = result
+			report.OK = report.OK && result == "ok"
+		}()
+	}
+	wg.Wait()
+
+	if len(tests) == 0 {
+		return report, fmt.Errorf("no destinations are configured")
+	}
+	slog.InfoContext(ctx, "Self-test", "destinations", report.Destinations)
+	return report, nil
+}
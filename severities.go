@@ -0,0 +1,86 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SeverityOverride changes how alarms of one severity are handled, configured by the SEVERITIES JSON object keyed by
+// severity, e.g. {"critical": {"mentions": "<!here>", "destinations": ["pagerduty"]}}.  Template and Channel apply
+// when the alarm's route doesn't name one, Mentions are prefixed to ALARM notifications, Destinations limits which of
+// the DESTINATIONS are sent the alarm, and the rest override the suppression policy.
+type SeverityOverride struct {
+	Template               string   `json:"template,omitempty"`
+	Channel                string   `json:"channel,omitempty"`
+	Mentions               string   `json:"mentions,omitempty"`
+	Destinations           []string `json:"destinations,omitempty"`
+	Suppress               bool     `json:"suppress,omitempty"`
+	SuppressOK             bool     `json:"suppress_ok,omitempty"`
+	InsufficientDataPolicy string   `json:"insufficient_data_policy,omitempty"`
+}
+
+// parseSeverityOverrides parses the SEVERITIES setting
+func parseSeverityOverrides(value string) (map[Severity]SeverityOverride, error) {
+	parsed := map[string]SeverityOverride{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, err
+	}
+	overrides := map[Severity]SeverityOverride{}
+	for name, override := range parsed {
+		severity, err := ParseSeverity(name)
+		if err != nil {
+			return nil, err
+		}
+		if override.Template != "" {
+			if _, ok := templates[override.Template]; !ok {
+				return nil, fmt.Errorf("severity %s: unknown template %q", name, override.Template)
+			}
+		}
+		if err := validChannel(override.Channel); err != nil {
+			return nil, fmt.Errorf("severity %s: %s", name, err)
+		}
+		for _, destination := range override.Destinations {
+			if !hasDestination(destination) {
+				return nil, fmt.Errorf("severity %s: unknown destination %q", name, destination)
+			}
+		}
+		override.InsufficientDataPolicy = strings.ToLower(override.InsufficientDataPolicy)
+		switch override.InsufficientDataPolicy {
+		case "", insufficientDataNotify, insufficientDataDrop, insufficientDataDowngrade, insufficientDataDelay:
+		default:
+			return nil, fmt.Errorf("severity %s: unknown insufficient_data_policy %q", name, override.InsufficientDataPolicy)
+		}
+		overrides[severity] = override
+	}
+	return overrides, nil
+}
+
+// severityOverride the override for the severity, empty when there isn't one
+func severityOverride(severity Severity) SeverityOverride {
+	return severityOverrides[severity]
+}
+
+// hasDestination whether DESTINATIONS names the destination
+func hasDestination(name string) bool {
+	for _, destination := range destinations {
+		if destination.Name == name {
+			return true
+		}
+	}
+	return false
+}
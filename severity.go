@@ -0,0 +1,135 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Severity how urgent an alarm is, P1 being the most urgent
+type Severity int
+
+// Severities from most to least urgent
+const (
+	SeverityCritical Severity = iota + 1
+	SeverityHigh
+	SeverityWarning
+	SeverityInfo
+)
+
+var (
+	severityNames = map[Severity]string{
+		SeverityCritical: "critical",
+		SeverityHigh:     "high",
+		SeverityWarning:  "warning",
+		SeverityInfo:     "info",
+	}
+	severityEmoji = map[Severity]string{
+		SeverityCritical: ":red_circle:",
+		SeverityHigh:     ":large_orange_circle:",
+		SeverityWarning:  ":large_yellow_circle:",
+		SeverityInfo:     ":large_blue_circle:",
+	}
+	severityColors = map[Severity]string{
+		SeverityCritical: "danger",
+		SeverityHigh:     "#f2711c",
+		SeverityWarning:  "warning",
+		SeverityInfo:     "#439fe0",
+	}
+
+	// Alarms declare their severity in the name or description, e.g. "[P1]" or "severity: critical"
+	severityMarker = regexp.MustCompile(`(?i)(?:\[|severity\s*[:=]\s*)(p[1-4]|critical|high|warning|info)\b`)
+)
+
+// String the P1-P4 label for the severity
+func (s Severity) String() string {
+	return fmt.Sprintf("P%d", int(s))
+}
+
+// Name the critical-info name for the severity
+func (s Severity) Name() string {
+	return severityNames[s]
+}
+
+// ParseSeverity parses either a P1-P4 label or a critical-info name
+func ParseSeverity(value string) (Severity, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for severity, name := range severityNames {
+		if value == name || value == strings.ToLower(severity.String()) {
+			return severity, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q", value)
+}
+
+// alarmSeverity derives the severity from the marker in the alarm description or name, falling back to the default
+func alarmSeverity(cloudWatchAlarmEvent CloudWatchAlarmEvent) Severity {
+	for _, text := range []string{cloudWatchAlarmEvent.AlarmDescription, cloudWatchAlarmEvent.AlarmName} {
+		if match := severityMarker.FindStringSubmatch(text); match != nil {
+			if severity, err := ParseSeverity(match[1]); err == nil {
+				return severity
+			}
+		}
+	}
+	return defaultSeverity
+}
+
+// severityBadge the emoji and label prefixed to the title.  Only ALARMs get the severity colored emoji so a
+// resolution or missing data doesn't read like an active problem.
+func severityBadge(severity Severity, state string) string {
+	emoji := severityEmoji[severity]
+	if state == "OK" {
+		emoji = ":white_check_mark:"
+	} else if state == "INSUFFICIENT_DATA" {
+		emoji = ":grey_question:"
+	}
+	return fmt.Sprintf("%s [%s]", emoji, severity)
+}
+
+// severityColor the attachment color for the severity and state
+func severityColor(severity Severity, state string) string {
+	if state == "OK" {
+		return "good"
+	} else if state == "INSUFFICIENT_DATA" {
+		return "warning"
+	}
+	return severityColors[severity]
+}
+
+// statePriority orders states by how much attention they need, ALARM first and OK last
+func statePriority(state string) int {
+	switch state {
+	case "ALARM":
+		return 0
+	case "INSUFFICIENT_DATA":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// sortByPriority sorts the notifications by state then severity so critical alarms aren't buried in a batch
+func sortByPriority(notifications []*notification) {
+	sort.SliceStable(notifications, func(i, j int) bool {
+		iState, jState := statePriority(notifications[i].cloudWatchAlarmEvent.NewStateValue), statePriority(notifications[j].cloudWatchAlarmEvent.NewStateValue)
+		if iState != jState {
+			return iState < jState
+		}
+		return notifications[i].severity < notifications[j].severity
+	})
+}
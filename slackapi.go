@@ -0,0 +1,246 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+const (
+	slackAPIURL = "https://slack.com/api/"
+	// Event type Slack Workflow Builder and other apps key off of in message metadata
+	slackMetadataEventType = "cloudwatch_alarm"
+	// How far back in the channel to look for the ALARM message an OK resolves
+	slackHistoryLimit = 200
+)
+
+// SlackMessage chat.postMessage payload used in bot-token mode
+type SlackMessage struct {
+	Channel        string                   `json:"channel"`
+	ThreadTs       string                   `json:"thread_ts,omitempty"`
+	ReplyBroadcast bool                     `json:"reply_broadcast,omitempty"`
+	Text           string                   `json:"text,omitempty"`
+	Blocks         []map[string]interface{} `json:"blocks,omitempty"`
+	Attachments    []slack.Attachment       `json:"attachments,omitempty"`
+	Metadata       *SlackMessageMetadata    `json:"metadata,omitempty"`
+}
+
+// SlackMessageUpdate chat.update payload.  Attachments are passed through untouched from the message being updated.
+type SlackMessageUpdate struct {
+	Channel     string                   `json:"channel"`
+	Ts          string                   `json:"ts"`
+	Text        string                   `json:"text,omitempty"`
+	Blocks      []map[string]interface{} `json:"blocks"`
+	Attachments []json.RawMessage        `json:"attachments,omitempty"`
+}
+
+// SlackMessageMetadata structured metadata attached to a posted message
+type SlackMessageMetadata struct {
+	EventType    string                 `json:"event_type"`
+	EventPayload map[string]interface{} `json:"event_payload"`
+}
+
+// SlackAPIResponse the fields common to every Slack Web API response
+type SlackAPIResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	Ts      string `json:"ts"`
+}
+
+// SlackHistoryResponse conversations.history response
+type SlackHistoryResponse struct {
+	SlackAPIResponse
+	Messages []SlackHistoryMessage `json:"messages"`
+}
+
+// SlackHistoryMessage a message from conversations.history
+type SlackHistoryMessage struct {
+	Ts       string                `json:"ts"`
+	Metadata *SlackMessageMetadata `json:"metadata"`
+}
+
+// SlackReactionsResponse reactions.get response
+type SlackReactionsResponse struct {
+	SlackAPIResponse
+	Message struct {
+		Reactions []struct {
+			Name  string   `json:"name"`
+			Users []string `json:"users"`
+		} `json:"reactions"`
+	} `json:"message"`
+}
+
+// alarmMetadata structured metadata describing the alarm the message was posted for
+func alarmMetadata(cloudWatchAlarmEvent CloudWatchAlarmEvent, severity Severity) *SlackMessageMetadata {
+	return &SlackMessageMetadata{
+		EventType: slackMetadataEventType,
+		EventPayload: map[string]interface{}{
+			"alarm_arn":      cloudWatchAlarmEvent.AlarmARN,
+			"alarm_name":     cloudWatchAlarmEvent.AlarmName,
+			"state":          cloudWatchAlarmEvent.NewStateValue,
+			"previous_state": cloudWatchAlarmEvent.OldStateValue,
+			"severity":       severity.String(),
+			"account_id":     cloudWatchAlarmEvent.AWSAccountID,
+			"region":         cloudWatchAlarmEvent.Region,
+		},
+	}
+}
+
+// slackAPI calls a Slack Web API method with the bot token, decoding the response into out.  Read methods don't accept
+// JSON bodies so url.Values are sent form encoded instead.
+func slackAPI(ctx context.Context, method string, in interface{}, out interface{}) error {
+	return slackAPIWithToken(ctx, currentSlackBotToken(), method, in, out)
+}
+
+// slackAPIWithToken calls the Web API method with the bot token of another workspace
+func slackAPIWithToken(ctx context.Context, token string, method string, in interface{}, out interface{}) error {
+	var body io.Reader
+	contentType := "application/json; charset=utf-8"
+	if form, ok := in.(url.Values); ok {
+		body = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	} else {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, slackAPIURL+method, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack %s returned %s", method, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// revokedTokenErrors the errors the Web API answers with when the bot token has been rotated or revoked
+var revokedTokenErrors = map[string]bool{
+	"invalid_auth":  true,
+	"not_authed":    true,
+	"token_revoked": true,
+	"token_expired": true,
+}
+
+// postMessage posts the message with chat.postMessage returning the channel and ts of the new message
+func postMessage(ctx context.Context, message SlackMessage) (SlackAPIResponse, error) {
+	started := time.Now()
+	response := SlackAPIResponse{}
+	err := slackAPI(ctx, "chat.postMessage", message, &response)
+	if err == nil && !response.OK {
+		err = fmt.Errorf("slack chat.postMessage failed: %s", response.Error)
+	}
+	auditDelivery(ctx, "slack:"+message.Channel, message, response, err, started)
+	if revokedTokenErrors[response.Error] && reloadSecrets(ctx) {
+		return postMessage(ctx, message)
+	}
+	return response, err
+}
+
+// addReaction reacts to the message with the named emoji, treating an existing reaction as success
+func addReaction(ctx context.Context, channel string, ts string, name string) error {
+	response := SlackAPIResponse{}
+	err := slackAPI(ctx, "reactions.add", map[string]string{
+		"channel":   channel,
+		"timestamp": ts,
+		"name":      strings.Trim(name, ":"),
+	}, &response)
+	if err != nil {
+		return err
+	}
+	if !response.OK && response.Error != "already_reacted" {
+		return fmt.Errorf("slack reactions.add failed: %s", response.Error)
+	}
+	return nil
+}
+
+// findAlarmMessage searches the recent channel history for the latest ALARM message posted for the alarm, returning
+// an empty ts when there isn't one
+func findAlarmMessage(ctx context.Context, channel string, alarmARN string) (string, error) {
+	response := SlackHistoryResponse{}
+	err := slackAPI(ctx, "conversations.history", url.Values{
+		"channel":              {channel},
+		"limit":                {fmt.Sprintf("%d", slackHistoryLimit)},
+		"include_all_metadata": {"true"},
+	}, &response)
+	if err != nil {
+		return "", err
+	}
+	if !response.OK {
+		return "", fmt.Errorf("slack conversations.history failed: %s", response.Error)
+	}
+
+	// History is returned newest first
+	for _, message := range response.Messages {
+		if message.Metadata == nil || message.Metadata.EventType != slackMetadataEventType {
+			continue
+		}
+		if message.Metadata.EventPayload["alarm_arn"] != alarmARN {
+			continue
+		}
+		if message.Metadata.EventPayload["state"] != "ALARM" {
+			// The alarm already resolved, or never went into ALARM, since its last notification
+			return "", nil
+		}
+		return message.Ts, nil
+	}
+	return "", nil
+}
+
+// firstReaction the first user to react to the message, empty if nobody has
+func firstReaction(ctx context.Context, channel string, ts string) (string, error) {
+	response := SlackReactionsResponse{}
+	err := slackAPI(ctx, "reactions.get", url.Values{
+		"channel":   {channel},
+		"timestamp": {ts},
+	}, &response)
+	if err != nil {
+		return "", err
+	}
+	if !response.OK {
+		return "", fmt.Errorf("slack reactions.get failed: %s", response.Error)
+	}
+
+	for _, reaction := range response.Message.Reactions {
+		if len(reaction.Users) != 0 {
+			return reaction.Users[0], nil
+		}
+	}
+	return "", nil
+}
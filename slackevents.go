@@ -0,0 +1,125 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SlackEventCallback an Events API request
+type SlackEventCallback struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Event     struct {
+		Type     string `json:"type"`
+		User     string `json:"user"`
+		Reaction string `json:"reaction"`
+		Item     struct {
+			Type    string `json:"type"`
+			Channel string `json:"channel"`
+			Ts      string `json:"ts"`
+		} `json:"item"`
+	} `json:"event"`
+}
+
+// handleEvent handles an Events API request returning the body to respond with
+func handleEvent(ctx context.Context, body string) (string, error) {
+	callback := SlackEventCallback{}
+	if err := json.Unmarshal([]byte(body), &callback); err != nil {
+		return "", err
+	}
+
+	switch callback.Type {
+	case "url_verification":
+		return callback.Challenge, nil
+	case "event_callback":
+		if callback.Event.Type == "reaction_added" && callback.Event.Item.Type == "message" && callback.Event.Reaction == strings.Trim(slackSnoozeReaction, ":") {
+			return "", snooze(ctx, callback.Event.User, callback.Event.Item.Channel, callback.Event.Item.Ts)
+		}
+	}
+	return "", nil
+}
+
+// snooze suppresses the alarm the message was posted for, for the snooze duration
+func snooze(ctx context.Context, user string, channel string, ts string) error {
+	if stateStore == nil {
+		return fmt.Errorf("snoozing requires STATE_TABLE to be configured")
+	}
+
+	message, err := alarmMessage(ctx, channel, ts)
+	if err != nil || message == nil {
+		return err
+	}
+
+	alarmARN, _ := message.Metadata.EventPayload["alarm_arn"].(string)
+	alarmName, _ := message.Metadata.EventPayload["alarm_name"].(string)
+	// A suppression without an alarm would match every alarm
+	if alarmARN == "" {
+		return fmt.Errorf("message %s in %s has no alarm_arn metadata to snooze", ts, channel)
+	}
+	now := time.Now()
+	err = stateStore.PutSuppression(ctx, Suppression{
+		// Slack retries events it doesn't get a quick answer for, keying on the message keeps those from stacking up
+		ID:       fmt.Sprintf("snooze-%s-%s", channel, ts),
+		AlarmARN: alarmARN,
+		Start:    now,
+		End:      now.Add(slackSnoozeDuration),
+		Reason:   fmt.Sprintf("snoozed by <@%s>", user),
+	})
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Snoozed", "alarm_name", alarmName, "user", user, "duration", slackSnoozeDuration.String())
+
+	_, err = postMessage(ctx, SlackMessage{
+		Channel:  channel,
+		ThreadTs: ts,
+		Text:     fmt.Sprintf(":zzz: <@%s> snoozed %s for %s", user, alarmName, slackSnoozeDuration),
+	})
+	return err
+}
+
+// alarmMessage the alarm message at ts in the channel, nil when the message isn't one the notifier posted
+func alarmMessage(ctx context.Context, channel string, ts string) (*SlackHistoryMessage, error) {
+	response := SlackHistoryResponse{}
+	err := slackAPI(ctx, "conversations.history", url.Values{
+		"channel":              {channel},
+		"latest":               {ts},
+		"inclusive":            {"true"},
+		"limit":                {"1"},
+		"include_all_metadata": {"true"},
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if !response.OK {
+		return nil, fmt.Errorf("slack conversations.history failed: %s", response.Error)
+	}
+
+	if len(response.Messages) == 0 || response.Messages[0].Ts != ts {
+		return nil, nil
+	}
+	message := response.Messages[0]
+	if message.Metadata == nil || message.Metadata.EventType != slackMetadataEventType {
+		return nil, nil
+	}
+	return &message, nil
+}
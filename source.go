@@ -0,0 +1,118 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"strings"
+)
+
+// regionNames the display name CloudWatch puts in the Region of alarm events it publishes to SNS, for each region code
+var regionNames = map[string]string{
+	"us-east-1":      "US East (N. Virginia)",
+	"us-east-2":      "US East (Ohio)",
+	"us-west-1":      "US West (N. California)",
+	"us-west-2":      "US West (Oregon)",
+	"af-south-1":     "Africa (Cape Town)",
+	"ap-east-1":      "Asia Pacific (Hong Kong)",
+	"ap-south-1":     "Asia Pacific (Mumbai)",
+	"ap-south-2":     "Asia Pacific (Hyderabad)",
+	"ap-northeast-1": "Asia Pacific (Tokyo)",
+	"ap-northeast-2": "Asia Pacific (Seoul)",
+	"ap-northeast-3": "Asia Pacific (Osaka)",
+	"ap-southeast-1": "Asia Pacific (Singapore)",
+	"ap-southeast-2": "Asia Pacific (Sydney)",
+	"ap-southeast-3": "Asia Pacific (Jakarta)",
+	"ap-southeast-4": "Asia Pacific (Melbourne)",
+	"ca-central-1":   "Canada (Central)",
+	"eu-central-1":   "EU (Frankfurt)",
+	"eu-central-2":   "EU (Zurich)",
+	"eu-west-1":      "EU (Ireland)",
+	"eu-west-2":      "EU (London)",
+	"eu-west-3":      "EU (Paris)",
+	"eu-north-1":     "EU (Stockholm)",
+	"eu-south-1":     "EU (Milan)",
+	"eu-south-2":     "EU (Spain)",
+	"il-central-1":   "Israel (Tel Aviv)",
+	"me-central-1":   "Middle East (UAE)",
+	"me-south-1":     "Middle East (Bahrain)",
+	"sa-east-1":      "South America (Sao Paulo)",
+	"cn-north-1":     "China (Beijing)",
+	"cn-northwest-1": "China (Ningxia)",
+	"us-gov-east-1":  "AWS GovCloud (US-East)",
+	"us-gov-west-1":  "AWS GovCloud (US-West)",
+}
+
+// consoleHosts the host of the console in each partition
+var consoleHosts = map[string]string{
+	"aws":        "console.aws.amazon.com",
+	"aws-cn":     "console.amazonaws.cn",
+	"aws-us-gov": "console.amazonaws-us-gov.com",
+}
+
+// EventSource the partition, account and region an alarm event came from.  Events aggregated from across an
+// organization all arrive through the same topic or bus, so this is taken from the alarm's ARN rather than from
+// where the event was collected.
+type EventSource struct {
+	Partition string
+	AccountID string
+	Region    string
+}
+
+// Source where the alarm event came from, falling back to what the event says when it has no ARN
+func (cloudWatchAlarmEvent CloudWatchAlarmEvent) Source() EventSource {
+	parts := strings.Split(cloudWatchAlarmEvent.AlarmARN, ":")
+	if len(parts) < 5 {
+		return EventSource{Partition: "aws", AccountID: cloudWatchAlarmEvent.AWSAccountID}
+	}
+	return EventSource{Partition: parts[1], AccountID: parts[4], Region: parts[3]}
+}
+
+// regionPartition the partition a region code is in
+func regionPartition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
+// consoleHost the host of the console for the partition, the commercial one when it isn't known
+func consoleHost(partition string) string {
+	if host, ok := consoleHosts[partition]; ok {
+		return host
+	}
+	return consoleHosts["aws"]
+}
+
+// normalizeAlarm makes alarm events look the same however they arrived: the account is the one in the alarm's ARN and
+// the region is its display name, which EventBridge and alarm actions give as the code
+func normalizeAlarm(cloudWatchAlarmEvent CloudWatchAlarmEvent) CloudWatchAlarmEvent {
+	source := cloudWatchAlarmEvent.Source()
+	if source.AccountID != "" {
+		cloudWatchAlarmEvent.AWSAccountID = source.AccountID
+	}
+	region := cloudWatchAlarmEvent.Region
+	if region == "" {
+		region = source.Region
+	}
+	if name, ok := regionNames[region]; ok {
+		region = name
+	}
+	cloudWatchAlarmEvent.Region = region
+	return cloudWatchAlarmEvent
+}
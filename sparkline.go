@@ -0,0 +1,143 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+const sparklineDatapoints = 20
+
+var sparklineTicks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders the values as a row of unicode block characters scaled between the min and max value
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	min, max := values[0], values[0]
+	for _, value := range values {
+		if value < min {
+			min = value
+		}
+		if value > max {
+			max = value
+		}
+	}
+
+	var builder strings.Builder
+	for _, value := range values {
+		tick := 0
+		if max > min {
+			tick = int((value - min) / (max - min) * float64(len(sparklineTicks)-1))
+		}
+		builder.WriteRune(sparklineTicks[tick])
+	}
+	return builder.String()
+}
+
+// metricHistory fetches the last sparklineDatapoints datapoints of the metric the alarm is evaluating, oldest first,
+// from the alarm's region.  There's no history for an alarm in another account.
+func metricHistory(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent) ([]float64, error) {
+	trigger := cloudWatchAlarmEvent.Trigger
+	if trigger.MetricName == "" || trigger.Period <= 0 {
+		return nil, nil
+	}
+
+	dimensions := []*cloudwatch.Dimension{}
+	for _, dimension := range trigger.Dimensions {
+		dimensions = append(dimensions, &cloudwatch.Dimension{
+			Name:  aws.String(dimension.Name),
+			Value: aws.String(dimension.Value),
+		})
+	}
+
+	end := time.Now()
+	period := time.Duration(trigger.Period) * time.Second
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String(trigger.Namespace),
+		MetricName: aws.String(trigger.MetricName),
+		Dimensions: dimensions,
+		StartTime:  aws.Time(end.Add(-sparklineDatapoints * period)),
+		EndTime:    aws.Time(end),
+		Period:     aws.Int64(int64(trigger.Period)),
+	}
+
+	statistic := triggerStatistic(trigger.Statistic)
+	if trigger.ExtendedStatistic != "" {
+		input.ExtendedStatistics = []*string{aws.String(trigger.ExtendedStatistic)}
+	} else {
+		input.Statistics = []*string{aws.String(statistic)}
+	}
+
+	client, err := alarmCloudWatchService(ctx, cloudWatchAlarmEvent)
+	if err != nil || client == nil {
+		return nil, err
+	}
+	output, err := client.GetMetricStatisticsWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	datapoints := output.Datapoints
+	sort.Slice(datapoints, func(i, j int) bool {
+		return aws.TimeValue(datapoints[i].Timestamp).Before(aws.TimeValue(datapoints[j].Timestamp))
+	})
+
+	values := []float64{}
+	for _, datapoint := range datapoints {
+		switch {
+		case trigger.ExtendedStatistic != "":
+			values = append(values, aws.Float64Value(datapoint.ExtendedStatistics[trigger.ExtendedStatistic]))
+		case statistic == cloudwatch.StatisticSum:
+			values = append(values, aws.Float64Value(datapoint.Sum))
+		case statistic == cloudwatch.StatisticMinimum:
+			values = append(values, aws.Float64Value(datapoint.Minimum))
+		case statistic == cloudwatch.StatisticMaximum:
+			values = append(values, aws.Float64Value(datapoint.Maximum))
+		case statistic == cloudwatch.StatisticSampleCount:
+			values = append(values, aws.Float64Value(datapoint.SampleCount))
+		default:
+			values = append(values, aws.Float64Value(datapoint.Average))
+		}
+	}
+	if len(values) > sparklineDatapoints {
+		values = values[len(values)-sparklineDatapoints:]
+	}
+	return values, nil
+}
+
+// triggerStatistic maps the upper snake case statistic on the alarm event (e.g. SAMPLE_COUNT) to the CloudWatch API name
+func triggerStatistic(statistic string) string {
+	switch strings.ToUpper(statistic) {
+	case "SAMPLE_COUNT":
+		return cloudwatch.StatisticSampleCount
+	case "SUM":
+		return cloudwatch.StatisticSum
+	case "MINIMUM":
+		return cloudwatch.StatisticMinimum
+	case "MAXIMUM":
+		return cloudwatch.StatisticMaximum
+	default:
+		return cloudwatch.StatisticAverage
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import "testing"
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		line   string
+	}{
+		{"no values", nil, ""},
+		{"flat", []float64{5, 5, 5}, "▁▁▁"},
+		{"every tick", []float64{0, 1, 2, 3, 4, 5, 6, 7}, "▁▂▃▄▅▆▇█"},
+		{"scaled", []float64{10, 0, 5}, "█▁▄"},
+		{"negative", []float64{-1, 1}, "▁█"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if line := sparkline(test.values); line != test.line {
+				t.Errorf("sparkline(%v) = %q, want %q", test.values, line, test.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// SQSBatchResponse the response telling SQS which messages of the batch failed so only they are retried, which Kinesis
+// reads too.  The event source mapping needs ReportBatchItemFailures enabled for it to be read.
+type SQSBatchResponse struct {
+	BatchItemFailures []SQSBatchItemFailure `json:"batchItemFailures"`
+}
+
+// SQSBatchItemFailure a message of the batch that failed
+type SQSBatchItemFailure struct {
+	ItemIdentifier string `json:"itemIdentifier"`
+}
+
+// HandleSQS function that handles SNS notifications fanned out to an SQS queue the function is subscribed to
+func HandleSQS(ctx context.Context, event events.SQSEvent) (SQSBatchResponse, error) {
+	response := SQSBatchResponse{BatchItemFailures: []SQSBatchItemFailure{}}
+
+	deliveries := []alarmDelivery{}
+	for _, message := range event.Records {
+		slog.InfoContext(ctx, "Processing SQS message", "sqs_message_id", message.MessageId, "event_source_arn", message.EventSourceARN)
+
+		sns := unwrapMessage(message.MessageId, []byte(message.Body))
+		switch sns.Type {
+		case snsSubscriptionConfirmation:
+			confirmation := SNSSubscriptionConfirmation{}
+			if err := json.Unmarshal([]byte(message.Body), &confirmation); err != nil {
+				slog.ErrorContext(ctx, "Decoding the subscription confirmation failed", "sqs_message_id", message.MessageId, "error", err)
+				continue
+			}
+			if err := confirmSubscription(ctx, confirmation); err != nil {
+				slog.ErrorContext(ctx, "Confirming the subscription failed", "topic_arn", confirmation.TopicArn, "error", err)
+				response.BatchItemFailures = append(response.BatchItemFailures, SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+			}
+			continue
+		case snsUnsubscribeConfirmation:
+			slog.InfoContext(ctx, "Unsubscribed", "topic_arn", sns.TopicArn)
+			continue
+		}
+
+		if !firstDelivery(ctx, sns) {
+			continue
+		}
+		cloudWatchAlarmEvent, ok := parseAlarm(sns.Message)
+		if !ok {
+			if other := renderMessage(ctx, sns); other != nil {
+				if err := postOtherMessages(ctx, []OtherMessage{*other}); err != nil {
+					forgetMessage(ctx, sns.MessageID)
+					response.BatchItemFailures = append(response.BatchItemFailures, SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+				}
+			}
+			continue
+		}
+		deliveries = append(deliveries, alarmDelivery{id: message.MessageId, sns: sns, cloudWatchAlarmEvent: cloudWatchAlarmEvent})
+	}
+
+	for _, n := range notifyAlarms(ctx, deliveries) {
+		if n.failed == nil {
+			continue
+		}
+		forgetDelivery(ctx, n)
+		response.BatchItemFailures = append(response.BatchItemFailures, SQSBatchItemFailure{ItemIdentifier: n.deliveryID})
+	}
+	return response, nil
+}
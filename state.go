@@ -0,0 +1,599 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const (
+	// Format of StateChangeTime on the alarm event
+	stateChangeTimeLayout = "2006-01-02T15:04:05.000-0700"
+	// Fixed width UTC layout so transition sort keys order lexically by time
+	transitionKeyLayout = "2006-01-02T15:04:05.000000000Z"
+)
+
+// AlarmState the last known state of an alarm along with where it was last notified
+type AlarmState struct {
+	AlarmARN        string    `dynamodbav:"AlarmArn"`
+	AlarmName       string    `dynamodbav:"AlarmName"`
+	State           string    `dynamodbav:"State"`
+	TransitionTime  time.Time `dynamodbav:"TransitionTime"`
+	Channel         string    `dynamodbav:"Channel,omitempty"`
+	MessageTs       string    `dynamodbav:"MessageTs,omitempty"`
+	IncidentRef     string    `dynamodbav:"IncidentRef,omitempty"`
+	Flapping        bool      `dynamodbav:"Flapping,omitempty"`
+	AcknowledgedBy  string    `dynamodbav:"AcknowledgedBy,omitempty"`
+	AcknowledgedAt  time.Time `dynamodbav:"AcknowledgedAt"`
+	Escalated       bool      `dynamodbav:"Escalated,omitempty"`
+	LastNotified    time.Time `dynamodbav:"LastNotified"`
+	Renotifications int       `dynamodbav:"Renotifications,omitempty"`
+	// Recoveries seconds from ALARM to OK of the most recent recoveries, oldest first
+	Recoveries []int64 `dynamodbav:"Recoveries,omitempty"`
+	// ConsecutiveAlarms how many times in a row the alarm has reported ALARM
+	ConsecutiveAlarms int `dynamodbav:"ConsecutiveAlarms,omitempty"`
+	// Pending the notification for this state hasn't been sent yet
+	Pending bool                 `dynamodbav:"Pending,omitempty"`
+	Subject string               `dynamodbav:"Subject,omitempty"`
+	Event   CloudWatchAlarmEvent `dynamodbav:"Event"`
+	// Details the attachment a summary's Details button posts into the thread of MessageTs, as JSON
+	Details string `dynamodbav:"Details,omitempty"`
+}
+
+// Transition a single state change in an alarm's history
+type Transition struct {
+	AlarmARN       string    `dynamodbav:"AlarmArn"`
+	AlarmName      string    `dynamodbav:"AlarmName"`
+	State          string    `dynamodbav:"State"`
+	TransitionTime time.Time `dynamodbav:"TransitionTime"`
+}
+
+// StateStore persists alarm state between invocations
+type StateStore interface {
+	// GetAlarmState the last recorded state of the alarm, nil if it has never been recorded
+	GetAlarmState(ctx context.Context, alarmARN string) (*AlarmState, error)
+	// PutAlarmState records the alarm's state replacing whatever was there
+	PutAlarmState(ctx context.Context, alarmState AlarmState) error
+	// AlarmStates the last recorded state of every alarm
+	AlarmStates(ctx context.Context) ([]AlarmState, error)
+	// PutTransition appends the state change to the alarm's history
+	PutTransition(ctx context.Context, transition Transition) error
+	// Transitions the alarm's state changes since the given time, oldest first
+	Transitions(ctx context.Context, alarmARN string, since time.Time) ([]Transition, error)
+	// Suppressions every recorded suppression, including ones that have expired or not started yet
+	Suppressions(ctx context.Context) ([]Suppression, error)
+	// PutSuppression records the suppression
+	PutSuppression(ctx context.Context, suppression Suppression) error
+	// MarkProcessed records the key as processed, returning false if it already had been
+	MarkProcessed(ctx context.Context, key string) (bool, error)
+	// RecordFiring counts the alarms that went into ALARM toward the storm window, returning every alarm counted in it
+	RecordFiring(ctx context.Context, firing []string, window time.Duration) ([]string, error)
+	// ForgetProcessed removes the key so it can be processed again
+	ForgetProcessed(ctx context.Context, key string) error
+	// GetAlarmGroup the correlated group of alarms for the key, nil if there isn't one
+	GetAlarmGroup(ctx context.Context, key string) (*AlarmGroup, error)
+	// PutAlarmGroup records the correlated group replacing whatever was there
+	PutAlarmGroup(ctx context.Context, group AlarmGroup) error
+	// QueueNotification holds the notification for the next digest
+	QueueNotification(ctx context.Context, queued QueuedNotification) error
+	// QueuedNotifications every notification held for the next digest, oldest first
+	QueuedNotifications(ctx context.Context) ([]QueuedNotification, error)
+	// DeleteQueuedNotifications removes the notifications once they've been sent
+	DeleteQueuedNotifications(ctx context.Context, queued []QueuedNotification) error
+	// PutDelivery records an outbound notification
+	PutDelivery(ctx context.Context, delivery Delivery) error
+	// Compact deletes everything past its retention, returning how many items were deleted
+	Compact(ctx context.Context) (int, error)
+}
+
+// DynamoDBStateStore StateStore backed by a DynamoDB table with a string partition key "pk" and string sort key "sk".
+// Alarm state lives under pk "alarm#<alarm arn>" and sk "state" with the alarm's history next to it under sk
+// "transition#<time>".  Suppressions are kept together under pk "suppression" keyed by their ID, processed keys
+// under pk "processed#<key>", correlated groups under pk "group#<key>", notifications held for the digest under pk
+// "queued" keyed by their ID and outbound notifications under pk "delivery#<UTC date>" and sk "<time>#<ID>".
+//
+// Everything but alarm state expires after the retention period through a "ttl" attribute, which the table's TTL
+// should be enabled on, with Compact cleaning up whatever TTL hasn't got to yet.
+type DynamoDBStateStore struct {
+	client    *dynamodb.DynamoDB
+	table     string
+	retention time.Duration
+}
+
+// dynamoDBKey the primary key attributes of every item in the state table
+type dynamoDBKey struct {
+	PK string `dynamodbav:"pk"`
+	SK string `dynamodbav:"sk"`
+}
+
+// NewDynamoDBStateStore a StateStore backed by the DynamoDB table keeping history for the retention period
+func NewDynamoDBStateStore(client *dynamodb.DynamoDB, table string, retention time.Duration) *DynamoDBStateStore {
+	return &DynamoDBStateStore{
+		client:    client,
+		table:     table,
+		retention: retention,
+	}
+}
+
+// expiresAt the ttl attribute of an item written at the given time, zero leaving it without one
+func (store *DynamoDBStateStore) expiresAt(from time.Time) int64 {
+	if store.retention <= 0 {
+		return 0
+	}
+	return from.Add(store.retention).Unix()
+}
+
+func alarmStateKey(alarmARN string) dynamoDBKey {
+	return dynamoDBKey{PK: "alarm#" + alarmARN, SK: "state"}
+}
+
+// GetAlarmState implements StateStore
+func (store *DynamoDBStateStore) GetAlarmState(ctx context.Context, alarmARN string) (*AlarmState, error) {
+	key, err := dynamodbattribute.MarshalMap(alarmStateKey(alarmARN))
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := store.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(store.table),
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Item) == 0 {
+		return nil, nil
+	}
+
+	alarmState := &AlarmState{}
+	if err := dynamodbattribute.UnmarshalMap(output.Item, alarmState); err != nil {
+		return nil, err
+	}
+	return alarmState, nil
+}
+
+// PutAlarmState implements StateStore
+func (store *DynamoDBStateStore) PutAlarmState(ctx context.Context, alarmState AlarmState) error {
+	item, err := dynamodbattribute.MarshalMap(struct {
+		dynamoDBKey
+		AlarmState
+	}{alarmStateKey(alarmState.AlarmARN), alarmState})
+	if err != nil {
+		return err
+	}
+
+	_, err = store.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(store.table),
+		Item:      item,
+	})
+	return err
+}
+
+// AlarmStates implements StateStore
+func (store *DynamoDBStateStore) AlarmStates(ctx context.Context) ([]AlarmState, error) {
+	alarmStates := []AlarmState{}
+	var unmarshalErr error
+	err := store.client.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName:        aws.String(store.table),
+		FilterExpression: aws.String("sk = :sk"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":sk": {S: aws.String("state")},
+		},
+	}, func(output *dynamodb.ScanOutput, lastPage bool) bool {
+		page := []AlarmState{}
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(output.Items, &page); unmarshalErr != nil {
+			return false
+		}
+		alarmStates = append(alarmStates, page...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return alarmStates, unmarshalErr
+}
+
+// PutTransition implements StateStore
+func (store *DynamoDBStateStore) PutTransition(ctx context.Context, transition Transition) error {
+	item, err := dynamodbattribute.MarshalMap(struct {
+		dynamoDBKey
+		Transition
+		TTL int64 `dynamodbav:"ttl,omitempty"`
+	}{dynamoDBKey{
+		PK: "alarm#" + transition.AlarmARN,
+		SK: "transition#" + transition.TransitionTime.UTC().Format(transitionKeyLayout),
+	}, transition, store.expiresAt(transition.TransitionTime)})
+	if err != nil {
+		return err
+	}
+
+	_, err = store.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(store.table),
+		Item:      item,
+	})
+	return err
+}
+
+// Transitions implements StateStore
+func (store *DynamoDBStateStore) Transitions(ctx context.Context, alarmARN string, since time.Time) ([]Transition, error) {
+	transitions := []Transition{}
+	var unmarshalErr error
+	err := store.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(store.table),
+		KeyConditionExpression: aws.String("pk = :pk AND sk BETWEEN :from AND :to"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk":   {S: aws.String("alarm#" + alarmARN)},
+			":from": {S: aws.String("transition#" + since.UTC().Format(transitionKeyLayout))},
+			":to":   {S: aws.String("transition#~")},
+		},
+	}, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		page := []Transition{}
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(output.Items, &page); unmarshalErr != nil {
+			return false
+		}
+		transitions = append(transitions, page...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transitions, unmarshalErr
+}
+
+// Suppressions implements StateStore
+func (store *DynamoDBStateStore) Suppressions(ctx context.Context) ([]Suppression, error) {
+	suppressions := []Suppression{}
+	var unmarshalErr error
+	err := store.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(store.table),
+		KeyConditionExpression: aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {S: aws.String("suppression")},
+		},
+	}, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		page := []Suppression{}
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(output.Items, &page); unmarshalErr != nil {
+			return false
+		}
+		suppressions = append(suppressions, page...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suppressions, unmarshalErr
+}
+
+// PutSuppression implements StateStore
+func (store *DynamoDBStateStore) PutSuppression(ctx context.Context, suppression Suppression) error {
+	item, err := dynamodbattribute.MarshalMap(struct {
+		dynamoDBKey
+		Suppression
+		TTL int64 `dynamodbav:"ttl,omitempty"`
+	}{dynamoDBKey{PK: "suppression", SK: suppression.ID}, suppression, store.expiresAt(suppression.End)})
+	if err != nil {
+		return err
+	}
+
+	_, err = store.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(store.table),
+		Item:      item,
+	})
+	return err
+}
+
+// MarkProcessed implements StateStore
+func (store *DynamoDBStateStore) MarkProcessed(ctx context.Context, key string) (bool, error) {
+	item, err := dynamodbattribute.MarshalMap(struct {
+		dynamoDBKey
+		ProcessedAt time.Time `dynamodbav:"ProcessedAt"`
+		TTL         int64     `dynamodbav:"ttl,omitempty"`
+	}{dynamoDBKey{PK: "processed#" + key, SK: "processed"}, time.Now(), store.expiresAt(time.Now())})
+	if err != nil {
+		return false, err
+	}
+
+	_, err = store.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(store.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// stormBuckets how many buckets the storm window is counted in, so the window slides along a bucket at a time
+const stormBuckets = 4
+
+// RecordFiring implements StateStore.  Each bucket of the window is an item holding the set of alarms that fired in it,
+// so counting is an update of the current bucket and a query of the window's buckets rather than a scan.
+func (store *DynamoDBStateStore) RecordFiring(ctx context.Context, firing []string, window time.Duration) ([]string, error) {
+	width := window / stormBuckets
+	if width < time.Second {
+		width = time.Second
+	}
+	bucket := time.Now().Truncate(width)
+
+	if len(firing) != 0 {
+		itemKey, err := dynamodbattribute.MarshalMap(dynamoDBKey{PK: "storm", SK: "firing#" + bucket.UTC().Format(transitionKeyLayout)})
+		if err != nil {
+			return nil, err
+		}
+		_, err = store.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName:                aws.String(store.table),
+			Key:                      itemKey,
+			UpdateExpression:         aws.String("ADD Alarms :firing SET #ttl = :ttl"),
+			ExpressionAttributeNames: map[string]*string{"#ttl": aws.String("ttl")},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":firing": {SS: aws.StringSlice(firing)},
+				":ttl":    {N: aws.String(strconv.FormatInt(bucket.Add(2*window).Unix(), 10))},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	counted := []string{}
+	var unmarshalErr error
+	err := store.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(store.table),
+		KeyConditionExpression: aws.String("pk = :pk AND sk BETWEEN :from AND :to"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk":   {S: aws.String("storm")},
+			":from": {S: aws.String("firing#" + bucket.Add(-(stormBuckets-1)*width).UTC().Format(transitionKeyLayout))},
+			":to":   {S: aws.String("firing#" + bucket.UTC().Format(transitionKeyLayout))},
+		},
+	}, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		page := []struct {
+			Alarms []string `dynamodbav:"Alarms,stringset"`
+		}{}
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(output.Items, &page); unmarshalErr != nil {
+			return false
+		}
+		for _, item := range page {
+			counted = append(counted, item.Alarms...)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counted, unmarshalErr
+}
+
+// ForgetProcessed implements StateStore
+func (store *DynamoDBStateStore) ForgetProcessed(ctx context.Context, key string) error {
+	itemKey, err := dynamodbattribute.MarshalMap(dynamoDBKey{PK: "processed#" + key, SK: "processed"})
+	if err != nil {
+		return err
+	}
+
+	_, err = store.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(store.table),
+		Key:       itemKey,
+	})
+	return err
+}
+
+func alarmGroupKey(key string) dynamoDBKey {
+	return dynamoDBKey{PK: "group#" + key, SK: "group"}
+}
+
+// GetAlarmGroup implements StateStore
+func (store *DynamoDBStateStore) GetAlarmGroup(ctx context.Context, key string) (*AlarmGroup, error) {
+	itemKey, err := dynamodbattribute.MarshalMap(alarmGroupKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := store.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(store.table),
+		Key:            itemKey,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Item) == 0 {
+		return nil, nil
+	}
+
+	group := &AlarmGroup{}
+	if err := dynamodbattribute.UnmarshalMap(output.Item, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// PutAlarmGroup implements StateStore
+func (store *DynamoDBStateStore) PutAlarmGroup(ctx context.Context, group AlarmGroup) error {
+	item, err := dynamodbattribute.MarshalMap(struct {
+		dynamoDBKey
+		AlarmGroup
+		TTL int64 `dynamodbav:"ttl,omitempty"`
+	}{alarmGroupKey(group.Key), group, store.expiresAt(group.LastAlarm)})
+	if err != nil {
+		return err
+	}
+
+	_, err = store.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(store.table),
+		Item:      item,
+	})
+	return err
+}
+
+// QueueNotification implements StateStore
+func (store *DynamoDBStateStore) QueueNotification(ctx context.Context, queued QueuedNotification) error {
+	item, err := dynamodbattribute.MarshalMap(struct {
+		dynamoDBKey
+		QueuedNotification
+		TTL int64 `dynamodbav:"ttl,omitempty"`
+	}{dynamoDBKey{PK: "queued", SK: queued.ID}, queued, store.expiresAt(queued.QueuedAt)})
+	if err != nil {
+		return err
+	}
+
+	_, err = store.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(store.table),
+		Item:      item,
+	})
+	return err
+}
+
+// QueuedNotifications implements StateStore
+func (store *DynamoDBStateStore) QueuedNotifications(ctx context.Context) ([]QueuedNotification, error) {
+	queued := []QueuedNotification{}
+	var unmarshalErr error
+	err := store.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(store.table),
+		KeyConditionExpression: aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {S: aws.String("queued")},
+		},
+	}, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		page := []QueuedNotification{}
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(output.Items, &page); unmarshalErr != nil {
+			return false
+		}
+		queued = append(queued, page...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return queued, unmarshalErr
+}
+
+// DeleteQueuedNotifications implements StateStore
+func (store *DynamoDBStateStore) DeleteQueuedNotifications(ctx context.Context, queued []QueuedNotification) error {
+	for _, q := range queued {
+		key, err := dynamodbattribute.MarshalMap(dynamoDBKey{PK: "queued", SK: q.ID})
+		if err != nil {
+			return err
+		}
+		_, err = store.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(store.table),
+			Key:       key,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutDelivery implements StateStore
+func (store *DynamoDBStateStore) PutDelivery(ctx context.Context, delivery Delivery) error {
+	sentAt := delivery.SentAt.UTC()
+	item, err := dynamodbattribute.MarshalMap(struct {
+		dynamoDBKey
+		Delivery
+		TTL int64 `dynamodbav:"ttl,omitempty"`
+	}{dynamoDBKey{
+		PK: "delivery#" + sentAt.Format("2006-01-02"),
+		SK: sentAt.Format(transitionKeyLayout) + "#" + delivery.ID,
+	}, delivery, store.expiresAt(delivery.SentAt)})
+	if err != nil {
+		return err
+	}
+
+	_, err = store.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(store.table),
+		Item:      item,
+	})
+	return err
+}
+
+// Compact implements StateStore.  TTL can take a couple of days to delete expired items, and items written before
+// there was a retention period don't have one, so anything that has expired or is older than the retention period is
+// deleted here.
+func (store *DynamoDBStateStore) Compact(ctx context.Context) (int, error) {
+	if store.retention <= 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	cutoff := now.Add(-store.retention)
+	keys := []map[string]*dynamodb.AttributeValue{}
+	err := store.client.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName:            aws.String(store.table),
+		ProjectionExpression: aws.String("pk, sk"),
+		FilterExpression: aws.String("#ttl < :now OR (attribute_not_exists(#ttl) AND " +
+			"((begins_with(sk, :transition) AND sk < :transitionCutoff) OR (sk = :processed AND ProcessedAt < :cutoff)))"),
+		ExpressionAttributeNames: map[string]*string{
+			"#ttl": aws.String("ttl"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":              {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+			":transition":       {S: aws.String("transition#")},
+			":transitionCutoff": {S: aws.String("transition#" + cutoff.UTC().Format(transitionKeyLayout))},
+			":processed":        {S: aws.String("processed")},
+			":cutoff":           {S: aws.String(cutoff.UTC().Format(time.RFC3339Nano))},
+		},
+	}, func(output *dynamodb.ScanOutput, lastPage bool) bool {
+		keys = append(keys, output.Items...)
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// BatchWriteItem takes at most 25 requests at a time
+	for start := 0; start < len(keys); start += 25 {
+		end := start + 25
+		if end > len(keys) {
+			end = len(keys)
+		}
+		requests := []*dynamodb.WriteRequest{}
+		for _, key := range keys[start:end] {
+			requests = append(requests, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: key}})
+		}
+
+		for attempt := 0; len(requests) != 0; attempt++ {
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+			}
+			output, err := store.client.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]*dynamodb.WriteRequest{store.table: requests},
+			})
+			if err != nil {
+				return start, err
+			}
+			requests = output.UnprocessedItems[store.table]
+		}
+	}
+	return len(keys), nil
+}
+
+// stateChangeTime when the alarm changed state, falling back to now if the event doesn't say
+func stateChangeTime(cloudWatchAlarmEvent CloudWatchAlarmEvent) time.Time {
+	changed, err := time.Parse(stateChangeTimeLayout, cloudWatchAlarmEvent.StateChangeTime)
+	if err != nil {
+		return time.Now()
+	}
+	return changed
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+const (
+	// Suppression recorded while a storm is in progress
+	stormSuppressionID = "storm"
+	stormTopNamespaces = 5
+)
+
+// detectStorm counts the distinct alarms that went into ALARM within the storm window, including the ones in this
+// batch.  Past the threshold a single summary is posted and a suppression is recorded that silences every ALARM, this
+// batch's included, until the storm has been quiet for a whole window.  Recoveries still go out.  When the summary
+// can't be posted nothing is suppressed, so the storm still gets through one alarm at a time.
+func detectStorm(ctx context.Context, notifications []*notification) error {
+	firing := []string{}
+	for _, n := range notifications {
+		if n.cloudWatchAlarmEvent.NewStateValue == "ALARM" {
+			firing = append(firing, n.cloudWatchAlarmEvent.AlarmARN+" "+n.cloudWatchAlarmEvent.Trigger.Namespace)
+		}
+	}
+	if len(firing) == 0 {
+		return nil
+	}
+
+	counted, err := stateStore.RecordFiring(ctx, firing, stormWindow)
+	if err != nil {
+		return err
+	}
+	// Each alarm is counted as its ARN and namespace, an alarm firing in more than one bucket being counted once
+	namespaces := map[string]string{}
+	for _, entry := range counted {
+		alarmARN, namespace, _ := strings.Cut(entry, " ")
+		namespaces[alarmARN] = namespace
+	}
+	if len(namespaces) <= stormThreshold {
+		return nil
+	}
+
+	now := time.Now()
+	suppressions, err := stateStore.Suppressions(ctx)
+	if err != nil {
+		return err
+	}
+	announced := false
+	for _, suppression := range suppressions {
+		if suppression.ID == stormSuppressionID && suppression.Active(now) {
+			announced = true
+		}
+	}
+
+	if !announced {
+		if err := postAttachments(ctx, slackMonitorChannel, []slack.Attachment{stormSummary(namespaces)}); err != nil {
+			return err
+		}
+	}
+
+	err = stateStore.PutSuppression(ctx, Suppression{
+		ID:     stormSuppressionID,
+		State:  "ALARM",
+		Start:  now,
+		End:    now.Add(stormWindow),
+		Reason: "alarm storm",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notifications {
+		if n.suppressed == "" && n.cloudWatchAlarmEvent.NewStateValue == "ALARM" {
+			n.suppressed = "alarm storm"
+			n.quiet = true
+		}
+	}
+	return nil
+}
+
+// stormSummary the one message posted in place of every alarm in the storm, given the namespace of each alarm firing
+func stormSummary(firing map[string]string) slack.Attachment {
+	namespaces := map[string]int{}
+	region := ""
+	for alarmARN, namespace := range firing {
+		namespaces[namespace]++
+		region = alarmRegion(alarmARN)
+	}
+
+	names := []string{}
+	for namespace := range namespaces {
+		names = append(names, namespace)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return namespaces[names[i]] > namespaces[names[j]]
+	})
+	if len(names) > stormTopNamespaces {
+		names = names[:stormTopNamespaces]
+	}
+
+	top := []string{}
+	for _, namespace := range names {
+		count := namespaces[namespace]
+		if namespace == "" {
+			namespace = "unknown"
+		}
+		top = append(top, fmt.Sprintf("%s (%d)", namespace, count))
+	}
+
+	return slack.Attachment{
+		Color: severityColors[SeverityCritical],
+		Title: fmt.Sprintf(":rotating_light: %d alarms fired in the last %s", len(firing), stormWindow),
+		Text: fmt.Sprintf("Top namespaces: %s\nIndividual notifications are suppressed until the storm passes. <%s|All alarms in ALARM>",
+			strings.Join(top, ", "), alarmListURL(region)),
+		Ts: time.Now().Unix(),
+	}
+}
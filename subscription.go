@@ -0,0 +1,83 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// Types of SNS message
+const (
+	snsSubscriptionConfirmation = "SubscriptionConfirmation"
+	snsUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+	snsNotification             = "Notification"
+)
+
+// Only SNS's own endpoints are followed so a forged confirmation can't make the function request arbitrary URLs
+var snsHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// SNSSubscriptionConfirmation the fields of an SNS SubscriptionConfirmation message needed to confirm it
+type SNSSubscriptionConfirmation struct {
+	Type         string `json:"Type"`
+	MessageID    string `json:"MessageId"`
+	TopicArn     string `json:"TopicArn"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+// confirmSubscription confirms the subscription by visiting its SubscribeURL when SNS_AUTO_CONFIRM is set, otherwise
+// it posts the link to the admin channel for someone to confirm by hand
+func confirmSubscription(ctx context.Context, confirmation SNSSubscriptionConfirmation) error {
+	subscribeURL, err := url.Parse(confirmation.SubscribeURL)
+	if err != nil {
+		return err
+	}
+	if subscribeURL.Scheme != "https" || !snsHost.MatchString(subscribeURL.Hostname()) {
+		return fmt.Errorf("refusing to confirm subscription to %s through %s", confirmation.TopicArn, confirmation.SubscribeURL)
+	}
+
+	if !snsAutoConfirm {
+		slog.InfoContext(ctx, "Posting subscription confirmation", "topic_arn", confirmation.TopicArn, "channel", slackAdminChannel)
+		return postAttachments(ctx, slackAdminChannel, []slack.Attachment{{
+			Color: "#439FE0",
+			Title: ":incoming_envelope: SNS subscription waiting for confirmation",
+			Text:  fmt.Sprintf("The notifier was subscribed to %s. <%s|Confirm the subscription> if it's expected.", confirmation.TopicArn, confirmation.SubscribeURL),
+			Ts:    time.Now().Unix(),
+		}})
+	}
+
+	req, err := http.NewRequest(http.MethodGet, subscribeURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("confirming subscription to %s returned %s", confirmation.TopicArn, resp.Status)
+	}
+	slog.InfoContext(ctx, "Confirmed subscription", "topic_arn", confirmation.TopicArn)
+	return nil
+}
@@ -0,0 +1,219 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Suppression a window during which matching notifications are suppressed, e.g. a declared maintenance window.  Every
+// criteria that is set has to match, so a suppression with none set matches every alarm.
+type Suppression struct {
+	ID       string `dynamodbav:"ID" json:"id"`
+	AlarmARN string `dynamodbav:"AlarmArn,omitempty" json:"alarm_arn,omitempty"`
+	// AlarmPattern glob (path.Match syntax) matched against the alarm name
+	AlarmPattern string `dynamodbav:"AlarmPattern,omitempty" json:"alarm_pattern,omitempty"`
+	// Tag key=value tag the alarm has to have
+	Tag       string `dynamodbav:"Tag,omitempty" json:"tag,omitempty"`
+	AccountID string `dynamodbav:"AccountId,omitempty" json:"account,omitempty"`
+	// State the state the alarm has to be transitioning to, e.g. ALARM to leave recoveries be
+	State string `dynamodbav:"State,omitempty" json:"state,omitempty"`
+	// Channel ID of the channel whose notifications are muted, with ChannelName its name
+	Channel     string    `dynamodbav:"Channel,omitempty" json:"channel,omitempty"`
+	ChannelName string    `dynamodbav:"ChannelName,omitempty" json:"channel_name,omitempty"`
+	Start       time.Time `dynamodbav:"Start" json:"start"`
+	End         time.Time `dynamodbav:"End" json:"end"`
+	Reason      string    `dynamodbav:"Reason,omitempty" json:"reason,omitempty"`
+}
+
+var (
+	parameterSuppressionsCache   []Suppression
+	parameterSuppressionsFetched time.Time
+	parameterSuppressionsMutex   sync.Mutex
+)
+
+// currentSuppressions the suppressions recorded in the state store along with the maintenance windows declared in
+// SUPPRESSIONS_PARAMETER.  Either being unavailable leaves the other's.
+func currentSuppressions(ctx context.Context) ([]Suppression, error) {
+	suppressions := parameterSuppressions(ctx)
+	if stateStore == nil {
+		return suppressions, nil
+	}
+	stored, err := stateStore.Suppressions(ctx)
+	return append(stored, suppressions...), err
+}
+
+// parameterSuppressions the maintenance windows in SUPPRESSIONS_PARAMETER, a JSON array of suppressions kept in SSM so
+// they can be declared without a state table, e.g.
+// [{"id": "db-upgrade", "alarm_pattern": "rds-*", "start": "2024-06-01T22:00:00Z", "end": "2024-06-02T02:00:00Z"}].
+// They're fetched again once CONFIG_REFRESH is up, keeping the last ones when that fails.
+func parameterSuppressions(ctx context.Context) []Suppression {
+	if suppressionsParameter == "" {
+		return nil
+	}
+
+	parameterSuppressionsMutex.Lock()
+	defer parameterSuppressionsMutex.Unlock()
+	if !parameterSuppressionsFetched.IsZero() && time.Since(parameterSuppressionsFetched) < configRefresh {
+		return parameterSuppressionsCache
+	}
+	parameterSuppressionsFetched = time.Now()
+
+	// Skipping the extension's cache so a window declared or called off takes effect within CONFIG_REFRESH
+	value, err := getParameter(bypassSecretsExtension(ctx), suppressionsParameter, true)
+	if err != nil {
+		slog.WarnContext(ctx, "Loading SUPPRESSIONS_PARAMETER failed", "error", err)
+		return parameterSuppressionsCache
+	}
+	suppressions := []Suppression{}
+	if err := json.Unmarshal([]byte(value), &suppressions); err != nil {
+		slog.WarnContext(ctx, "Decoding SUPPRESSIONS_PARAMETER failed", "error", err)
+		return parameterSuppressionsCache
+	}
+	for i := range suppressions {
+		if suppressions[i].ID == "" {
+			suppressions[i].ID = fmt.Sprintf("%s[%d]", suppressionsParameter, i)
+		}
+	}
+	parameterSuppressionsCache = suppressions
+	return parameterSuppressionsCache
+}
+
+// Active whether the suppression is in effect at the given time
+func (suppression Suppression) Active(at time.Time) bool {
+	return !at.Before(suppression.Start) && at.Before(suppression.End)
+}
+
+// Matches whether the suppression applies to the alarm posted to the channel.  Tags are only looked up when the
+// suppression needs them.
+func (suppression Suppression) Matches(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent, channel string) (bool, error) {
+	if suppression.Channel != "" && !suppression.Mutes(channel) {
+		return false, nil
+	}
+
+	if suppression.AccountID != "" && suppression.AccountID != cloudWatchAlarmEvent.AWSAccountID {
+		return false, nil
+	}
+
+	if suppression.State != "" && suppression.State != cloudWatchAlarmEvent.NewStateValue {
+		return false, nil
+	}
+
+	if suppression.AlarmARN != "" && suppression.AlarmARN != cloudWatchAlarmEvent.AlarmARN {
+		return false, nil
+	}
+
+	if suppression.AlarmPattern != "" {
+		matched, err := path.Match(suppression.AlarmPattern, cloudWatchAlarmEvent.AlarmName)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	if suppression.Tag != "" {
+		tags, err := alarmTags(ctx, cloudWatchAlarmEvent.AlarmARN)
+		if err != nil {
+			return false, err
+		}
+		parts := strings.SplitN(suppression.Tag, "=", 2)
+		value, ok := tags[parts[0]]
+		if !ok || (len(parts) == 2 && value != parts[1]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Mutes whether the suppression mutes the channel, which can be given by ID or by name with or without the leading #
+func (suppression Suppression) Mutes(channel string) bool {
+	if channel == suppression.Channel {
+		return true
+	}
+	return suppression.ChannelName != "" && strings.TrimPrefix(channel, "#") == suppression.ChannelName
+}
+
+// activeSuppression the first suppression in effect that matches the alarm posted to the channel, nil if there isn't
+// one.  A suppression that can't be matched, like one needing tags that can't be looked up, doesn't stop the rest being
+// tried, its error is only returned when none of them match.
+func activeSuppression(ctx context.Context, suppressions []Suppression, cloudWatchAlarmEvent CloudWatchAlarmEvent, channel string) (*Suppression, error) {
+	now := time.Now()
+	var matchErr error
+	for i := range suppressions {
+		if !suppressions[i].Active(now) {
+			continue
+		}
+		matched, err := suppressions[i].Matches(ctx, cloudWatchAlarmEvent, channel)
+		if err != nil {
+			matchErr = err
+			continue
+		}
+		if matched {
+			return &suppressions[i], nil
+		}
+	}
+	return nil, matchErr
+}
+
+// suppressOK whether the alarm has opted out of OK notifications, either by name or by tag
+func suppressOK(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent) (bool, error) {
+	for _, pattern := range okSuppressionPatterns {
+		if matched, _ := path.Match(pattern, cloudWatchAlarmEvent.AlarmName); matched {
+			return true, nil
+		}
+	}
+
+	if okSuppressionTag == "" {
+		return false, nil
+	}
+	return Suppression{Tag: okSuppressionTag}.Matches(ctx, cloudWatchAlarmEvent, "")
+}
+
+// tagSuppression the key=value suppression tag found on the alarm, or failing that on the resource behind it, empty if
+// neither has one.  Values are compared case insensitively so "Off" and "TRUE" work too.  SUPPRESSION_TAGS is empty
+// unless set, since looking the tags up costs two API calls per alarm and tag:GetResources and
+// cloudwatch:ListTagsForResource permissions.
+func tagSuppression(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent) (string, error) {
+	if len(suppressionTags) == 0 {
+		return "", nil
+	}
+
+	lookups := []func(context.Context, CloudWatchAlarmEvent) (map[string]string, error){
+		func(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent) (map[string]string, error) {
+			return alarmTags(ctx, cloudWatchAlarmEvent.AlarmARN)
+		},
+		resourceTags,
+	}
+	for _, lookup := range lookups {
+		tags, err := lookup(ctx, cloudWatchAlarmEvent)
+		if err != nil {
+			return "", err
+		}
+		for _, suppressionTag := range suppressionTags {
+			parts := strings.SplitN(suppressionTag, "=", 2)
+			value, ok := tags[parts[0]]
+			if ok && (len(parts) == 1 || strings.EqualFold(value, parts[1])) {
+				return suppressionTag, nil
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSuppressionActive(t *testing.T) {
+	start := time.Date(2018, 7, 23, 22, 0, 0, 0, time.UTC)
+	suppression := Suppression{Start: start, End: start.Add(4 * time.Hour)}
+	tests := []struct {
+		name   string
+		at     time.Time
+		active bool
+	}{
+		{"before", start.Add(-time.Minute), false},
+		{"at the start", start, true},
+		{"during", start.Add(2 * time.Hour), true},
+		{"at the end", start.Add(4 * time.Hour), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if active := suppression.Active(test.at); active != test.active {
+				t.Errorf("Active(%s) = %t, want %t", test.at, active, test.active)
+			}
+		})
+	}
+}
+
+func TestSuppressionMatches(t *testing.T) {
+	cloudWatchAlarmEvent := CloudWatchAlarmEvent{
+		AlarmName:     "rds-cpu-high",
+		AlarmARN:      "arn:aws:cloudwatch:us-east-1:123456789012:alarm:rds-cpu-high",
+		AWSAccountID:  "123456789012",
+		NewStateValue: "ALARM",
+	}
+	tests := []struct {
+		name        string
+		suppression Suppression
+		channel     string
+		matches     bool
+		err         bool
+	}{
+		{"everything", Suppression{}, "C0123", true, false},
+		{"alarm", Suppression{AlarmARN: cloudWatchAlarmEvent.AlarmARN}, "C0123", true, false},
+		{"another alarm", Suppression{AlarmARN: "arn:aws:cloudwatch:us-east-1:123456789012:alarm:other"}, "C0123", false, false},
+		{"pattern", Suppression{AlarmPattern: "rds-*"}, "C0123", true, false},
+		{"another pattern", Suppression{AlarmPattern: "ecs-*"}, "C0123", false, false},
+		{"bad pattern", Suppression{AlarmPattern: "rds-["}, "C0123", false, true},
+		{"account", Suppression{AccountID: "123456789012"}, "C0123", true, false},
+		{"another account", Suppression{AccountID: "210987654321"}, "C0123", false, false},
+		{"state", Suppression{State: "ALARM"}, "C0123", true, false},
+		{"another state", Suppression{State: "OK"}, "C0123", false, false},
+		{"channel", Suppression{Channel: "C0123"}, "C0123", true, false},
+		{"channel by name", Suppression{Channel: "C0123", ChannelName: "alarms"}, "#alarms", true, false},
+		{"another channel", Suppression{Channel: "C0123", ChannelName: "alarms"}, "C0456", false, false},
+		{"every criteria", Suppression{AlarmPattern: "rds-*", AccountID: "123456789012", State: "ALARM"}, "C0123", true, false},
+		{"one criteria off", Suppression{AlarmPattern: "rds-*", AccountID: "123456789012", State: "OK"}, "C0123", false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matches, err := test.suppression.Matches(context.Background(), cloudWatchAlarmEvent, test.channel)
+			if matches != test.matches || (err != nil) != test.err {
+				t.Errorf("Matches() = %t, %v, want %t, error %t", matches, err, test.matches, test.err)
+			}
+		})
+	}
+}
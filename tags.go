@@ -0,0 +1,142 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+)
+
+// Tags rarely change so they're cached across invocations of a warm container for a few minutes
+const alarmTagsTTL = 5 * time.Minute
+
+type cachedTags struct {
+	tags    map[string]string
+	fetched time.Time
+}
+
+// resourceARNFormats the ARN of the resource behind well known metric dimensions, formatted with the partition, region,
+// account and dimension value
+var resourceARNFormats = map[string]string{
+	"InstanceId":           "arn:%s:ec2:%s:%s:instance/%s",
+	"DBInstanceIdentifier": "arn:%s:rds:%s:%s:db:%s",
+	"FunctionName":         "arn:%s:lambda:%s:%s:function:%s",
+	"QueueName":            "arn:%s:sqs:%s:%s:%s",
+	"TopicName":            "arn:%s:sns:%s:%s:%s",
+	"TableName":            "arn:%s:dynamodb:%s:%s:table/%s",
+	"StreamName":           "arn:%s:kinesis:%s:%s:stream/%s",
+}
+
+var (
+	alarmTagsCache      = map[string]cachedTags{}
+	alarmTagsCacheMutex sync.Mutex
+)
+
+// alarmTags the tags on the alarm, looked up in its region.  An alarm in another account has none that can be seen.
+func alarmTags(ctx context.Context, alarmARN string) (map[string]string, error) {
+	alarmTagsCacheMutex.Lock()
+	cached, ok := alarmTagsCache[alarmARN]
+	alarmTagsCacheMutex.Unlock()
+	if ok && time.Since(cached.fetched) < alarmTagsTTL {
+		return cached.tags, nil
+	}
+
+	client, err := alarmCloudWatchService(ctx, CloudWatchAlarmEvent{AlarmARN: alarmARN})
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return map[string]string{}, nil
+	}
+	output, err := client.ListTagsForResourceWithContext(ctx, &cloudwatch.ListTagsForResourceInput{
+		ResourceARN: aws.String(alarmARN),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, tag := range output.Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	alarmTagsCacheMutex.Lock()
+	alarmTagsCache[alarmARN] = cachedTags{tags: tags, fetched: time.Now()}
+	alarmTagsCacheMutex.Unlock()
+	return tags, nil
+}
+
+// resourceARN the ARN of the resource the alarm's metric is about, empty if none of its dimensions identify one
+func resourceARN(cloudWatchAlarmEvent CloudWatchAlarmEvent) string {
+	parts := strings.Split(cloudWatchAlarmEvent.AlarmARN, ":")
+	if len(parts) < 5 {
+		return ""
+	}
+	for _, dimension := range cloudWatchAlarmEvent.Trigger.Dimensions {
+		if format, ok := resourceARNFormats[dimension.Name]; ok {
+			return fmt.Sprintf(format, parts[1], parts[3], parts[4], dimension.Value)
+		}
+	}
+	return ""
+}
+
+// resourceTags the tags on the resource the alarm's metric is about, empty if it can't be identified or is in another
+// account
+func resourceTags(ctx context.Context, cloudWatchAlarmEvent CloudWatchAlarmEvent) (map[string]string, error) {
+	resource := resourceARN(cloudWatchAlarmEvent)
+	if resource == "" {
+		return map[string]string{}, nil
+	}
+
+	alarmTagsCacheMutex.Lock()
+	cached, ok := alarmTagsCache[resource]
+	alarmTagsCacheMutex.Unlock()
+	if ok && time.Since(cached.fetched) < alarmTagsTTL {
+		return cached.tags, nil
+	}
+
+	client, err := alarmTaggingService(ctx, cloudWatchAlarmEvent)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return map[string]string{}, nil
+	}
+	output, err := client.GetResourcesWithContext(ctx, &resourcegroupstaggingapi.GetResourcesInput{
+		ResourceARNList: []*string{aws.String(resource)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, mapping := range output.ResourceTagMappingList {
+		for _, tag := range mapping.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+
+	alarmTagsCacheMutex.Lock()
+	alarmTagsCache[resource] = cachedTags{tags: tags, fetched: time.Now()}
+	alarmTagsCacheMutex.Unlock()
+	return tags, nil
+}
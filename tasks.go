@@ -0,0 +1,74 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// ScheduledTask the constant input of an EventBridge schedule invoking the notifier, e.g. {"task": "recheck"}
+type ScheduledTask struct {
+	Task string `json:"task"`
+}
+
+// scheduledTasks the tasks a schedule can run by name
+var scheduledTasks = map[string]func(context.Context) error{
+	"recheck":   recheckPending,
+	"escalate":  escalateUnacknowledged,
+	"renotify":  renotifyStillFiring,
+	"digest":    morningDigest,
+	"compact":   compactState,
+	"report":    noisyAlarmReport,
+	"firing":    stillFiringDigest,
+	"replay":    replayDeadLetters,
+	"heartbeat": heartbeat,
+}
+
+// statelessTasks the tasks that can run without a state store
+var statelessTasks = map[string]bool{
+	"firing":    true,
+	"replay":    true,
+	"heartbeat": true,
+}
+
+// HandleTask function that runs a scheduled task
+func HandleTask(ctx context.Context, task ScheduledTask) error {
+	run, ok := scheduledTasks[task.Task]
+	if !ok {
+		return fmt.Errorf("unknown scheduled task %q", task.Task)
+	}
+	if stateStore == nil && !statelessTasks[task.Task] {
+		return fmt.Errorf("scheduled task %q requires STATE_TABLE to be configured", task.Task)
+	}
+
+	// Tasks that only send notifications are skipped while the kill switch is off.  The heartbeat still runs so it
+	// doesn't go missing and page about the notifier being broken.
+	if !notificationsEnabled(ctx) && task.Task != "recheck" && task.Task != "compact" && task.Task != "heartbeat" {
+		slog.InfoContext(ctx, "Skipping scheduled task, notifications are disabled", "task", task.Task, "kill_switch", killSwitchParameter)
+		return nil
+	}
+
+	slog.InfoContext(ctx, "Running scheduled task", "task", task.Task)
+	return run(ctx)
+}
+
+// compactState deletes state past its retention
+func compactState(ctx context.Context) error {
+	deleted, err := stateStore.Compact(ctx)
+	slog.InfoContext(ctx, "Compacted the state table", "deleted", deleted)
+	return err
+}
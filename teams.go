@@ -0,0 +1,168 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	adaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+	adaptiveCardSchema      = "http://adaptivecards.io/schemas/adaptive-card.json"
+	adaptiveCardVersion     = "1.4"
+)
+
+// TeamsMessage the message posted to a Teams incoming webhook
+type TeamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []TeamsAttachment `json:"attachments"`
+}
+
+// TeamsAttachment wraps the adaptive card in the Teams message
+type TeamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     AdaptiveCard `json:"content"`
+}
+
+// AdaptiveCard https://adaptivecards.io/explorer/AdaptiveCard.html
+type AdaptiveCard struct {
+	Schema  string                   `json:"$schema"`
+	Type    string                   `json:"type"`
+	Version string                   `json:"version"`
+	Body    []map[string]interface{} `json:"body"`
+	Actions []map[string]interface{} `json:"actions,omitempty"`
+	MSTeams map[string]string        `json:"msteams,omitempty"`
+}
+
+// adaptiveCardStyles container style used to accent the card by severity
+var adaptiveCardStyles = map[Severity]string{
+	SeverityCritical: "attention",
+	SeverityHigh:     "attention",
+	SeverityWarning:  "warning",
+	SeverityInfo:     "accent",
+}
+
+// adaptiveCard renders the alarm as an adaptive card with the trigger details as a fact set and links to the console and
+// runbook.  The runbook snippet, when there is one, is tucked behind a ShowCard action.
+func adaptiveCard(n *notification) AdaptiveCard {
+	cloudWatchAlarmEvent, severity := n.cloudWatchAlarmEvent, n.severity
+
+	style := adaptiveCardStyles[severity]
+	if cloudWatchAlarmEvent.NewStateValue == "OK" {
+		style = "good"
+	} else if cloudWatchAlarmEvent.NewStateValue == "INSUFFICIENT_DATA" {
+		style = "warning"
+	}
+
+	facts := []map[string]interface{}{}
+	for _, fact := range [][2]string{
+		{"Severity", fmt.Sprintf("%s (%s)", severity, severity.Name())},
+		{"State", fmt.Sprintf("%s → %s", cloudWatchAlarmEvent.OldStateValue, cloudWatchAlarmEvent.NewStateValue)},
+		{"AccountID", cloudWatchAlarmEvent.AWSAccountID},
+		{"Region", cloudWatchAlarmEvent.Region},
+		{"Period", fmt.Sprintf("%v", cloudWatchAlarmEvent.Trigger.Period)},
+		{"Threshold", fmt.Sprintf("%v", cloudWatchAlarmEvent.Trigger.Threshold)},
+		{"Evaluated Periods", fmt.Sprintf("%v", cloudWatchAlarmEvent.Trigger.EvaluationPeriods)},
+		{"Comparison Operator", cloudWatchAlarmEvent.Trigger.ComparisonOperator},
+	} {
+		facts = append(facts, map[string]interface{}{"title": fact[0], "value": fact[1]})
+	}
+
+	actions := []map[string]interface{}{
+		{"type": "Action.OpenUrl", "title": "Open in Console", "url": consoleURL(cloudWatchAlarmEvent)},
+	}
+	if n.runbook != "" {
+		actions = append(actions, map[string]interface{}{"type": "Action.OpenUrl", "title": "Runbook", "url": n.runbook})
+	}
+	if n.runbookSnippet != "" {
+		actions = append(actions, map[string]interface{}{
+			"type":  "Action.ShowCard",
+			"title": "First Steps",
+			"card": map[string]interface{}{
+				"type": "AdaptiveCard",
+				"body": []map[string]interface{}{
+					{"type": "TextBlock", "text": n.runbookSnippet, "wrap": true},
+				},
+			},
+		})
+	}
+
+	return AdaptiveCard{
+		Schema:  adaptiveCardSchema,
+		Type:    "AdaptiveCard",
+		Version: adaptiveCardVersion,
+		Body: []map[string]interface{}{
+			{
+				"type":  "Container",
+				"style": style,
+				"bleed": true,
+				"items": []map[string]interface{}{
+					{"type": "TextBlock", "text": n.subject, "weight": "Bolder", "size": "Medium", "wrap": true},
+					{"type": "TextBlock", "text": cloudWatchAlarmEvent.NewStateReason, "wrap": true, "isSubtle": true},
+				},
+			},
+			{"type": "FactSet", "facts": facts},
+		},
+		Actions: actions,
+		MSTeams: map[string]string{"width": "Full"},
+	}
+}
+
+// sendTeams posts the adaptive card to the TEAMS_WEBHOOK
+func sendTeams(ctx context.Context, card AdaptiveCard) error {
+	resp, err := postTeams(ctx, currentTeamsWebhook(), "teams", card)
+	if rejectedCredentials(resp) && reloadSecrets(ctx) {
+		return sendTeams(ctx, card)
+	}
+	return err
+}
+
+// postTeams posts the adaptive card to the Teams incoming webhook, audited as the destination
+func postTeams(ctx context.Context, webhook string, destination string, card AdaptiveCard) (*http.Response, error) {
+	message := TeamsMessage{
+		Type:        "message",
+		Attachments: []TeamsAttachment{{ContentType: adaptiveCardContentType, Content: card}},
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	started := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		auditDelivery(ctx, destination, message, nil, err, started)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = fmt.Errorf("teams webhook returned %s", resp.Status)
+	}
+	auditDelivery(ctx, destination, message, resp.Status, err, started)
+	return resp, err
+}
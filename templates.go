@@ -0,0 +1,132 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"text/template"
+)
+
+// Template replaces the title and text of the notification's attachment.  Both are text/templates executed with the
+// templateData of the alarm, and either can be left empty to keep the usual one.
+type Template struct {
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text,omitempty"`
+
+	title *template.Template
+	text  *template.Template
+}
+
+// templateData what a template can refer to
+type templateData struct {
+	Subject  string
+	Event    CloudWatchAlarmEvent
+	Severity Severity
+	Runbook  string
+	Title    string
+	Text     string
+}
+
+// defaultTemplate the template alarms matched by a route without one are rendered with
+const defaultTemplate = "default"
+
+// parseTemplates parses the TEMPLATES setting, a JSON object of templates by name
+func parseTemplates(value string) (map[string]*Template, error) {
+	parsed := map[string]*Template{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, err
+	}
+	for name, tmpl := range parsed {
+		var err error
+		if tmpl.Title != "" {
+			if tmpl.title, err = template.New(name + " title").Parse(tmpl.Title); err != nil {
+				return nil, fmt.Errorf("template %s: %s", name, err)
+			}
+		}
+		if tmpl.Text != "" {
+			if tmpl.text, err = template.New(name + " text").Parse(tmpl.Text); err != nil {
+				return nil, fmt.Errorf("template %s: %s", name, err)
+			}
+		}
+	}
+	return parsed, nil
+}
+
+// applyTemplate renders the notification's attachment with the template its route names, or failing that its
+// account or severity, or the default template, then prefixes the severity's mentions.  A template that fails to execute leaves
+// the attachment as it was.
+func applyTemplate(n *notification) {
+	override := severityOverride(n.severity)
+	if override.Mentions != "" && n.cloudWatchAlarmEvent.NewStateValue == "ALARM" {
+		defer func() {
+			n.slackAttachment.Text, _ = truncate(override.Mentions+" "+n.slackAttachment.Text, slackTextLimit)
+		}()
+	}
+
+	name := route(n.cloudWatchAlarmEvent, n.severity).Template
+	if name == "" {
+		name = accountOverride(n.cloudWatchAlarmEvent).Template
+	}
+	if name == "" {
+		name = override.Template
+	}
+	if name == "" {
+		name = defaultTemplate
+	}
+	tmpl, ok := templates[name]
+	if !ok {
+		return
+	}
+
+	data := templateData{
+		Subject:  n.subject,
+		Event:    n.cloudWatchAlarmEvent,
+		Severity: n.severity,
+		Runbook:  n.runbook,
+		Title:    n.slackAttachment.Title,
+		Text:     n.slackAttachment.Text,
+	}
+	title, err := executeTemplate(tmpl.title, data)
+	if err != nil {
+		slog.Warn("Executing the template failed", "template", name, "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "error", err)
+		return
+	}
+	text, err := executeTemplate(tmpl.text, data)
+	if err != nil {
+		slog.Warn("Executing the template failed", "template", name, "alarm_arn", n.cloudWatchAlarmEvent.AlarmARN, "error", err)
+		return
+	}
+	if tmpl.title != nil {
+		n.slackAttachment.Title = title
+	}
+	if tmpl.text != nil {
+		n.slackAttachment.Text, _ = truncate(text, slackTextLimit)
+	}
+}
+
+// executeTemplate the template executed with the data, empty for a nil template
+func executeTemplate(tmpl *template.Template, data templateData) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	rendered := bytes.Buffer{}
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
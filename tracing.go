@@ -0,0 +1,78 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Where traces are sent
+const (
+	tracingXRay = "xray"
+	tracingOTel = "otel"
+)
+
+// traceInvocation starts the span everything in the invocation is recorded under, returning the func that ends it.
+// X-Ray already has the Lambda's segment to record under so there's nothing to start, but OpenTelemetry has to start
+// its own and flush it before the invocation freezes.
+func traceInvocation(ctx context.Context) (context.Context, func()) {
+	if tracing != tracingOTel {
+		return ctx, func() {}
+	}
+	ctx, span := otelTracer.Start(ctx, "invocation")
+	return ctx, func() {
+		span.End()
+		flushOpenTelemetry(ctx)
+	}
+}
+
+// trace runs fn in a subsegment, or span, called name, recording the error it returns.  With tracing off it just runs
+// fn.
+func trace(ctx context.Context, name string, fn func(context.Context) error) error {
+	switch tracing {
+	case tracingXRay:
+		return xray.Capture(ctx, name, fn)
+	case tracingOTel:
+		return otelSpan(ctx, name, fn)
+	}
+	return fn(ctx)
+}
+
+// traceTransport wraps the transport so every webhook and Slack API call shows up as its own subsegment
+func traceTransport(next http.RoundTripper) http.RoundTripper {
+	switch tracing {
+	case tracingXRay:
+		return xray.RoundTripper(next)
+	case tracingOTel:
+		return otelhttp.NewTransport(next)
+	}
+	return next
+}
+
+// traceClients records the calls the AWS clients make, the tag, state and history lookups done while enriching.
+// Only X-Ray can instrument the v1 SDK.
+func traceClients(clients ...*client.Client) {
+	if tracing != tracingXRay {
+		return
+	}
+	for _, c := range clients {
+		xray.AWS(c)
+	}
+}
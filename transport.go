@@ -0,0 +1,107 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// newTransport the transport every destination shares, so connections to Slack and the webhooks are kept alive and
+// reused across records and warm invocations.  Leaving http2 off keeps every connection on HTTP/1.1.  A response has
+// as long as each attempt does to start arriving.
+func newTransport(http2 bool, attemptTimeout time.Duration) *http.Transport {
+	transport := &http.Transport{
+		Proxy: outboundProxy(),
+		DialContext: (&net.Dialer{
+			Timeout:   3 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   3 * time.Second,
+		ResponseHeaderTimeout: attemptTimeout,
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          20,
+		MaxIdleConnsPerHost:   5,
+		IdleConnTimeout:       90 * time.Second,
+		// A custom DialContext stops the transport trying HTTP/2 on its own
+		ForceAttemptHTTP2: http2,
+	}
+	if !http2 {
+		// A non-nil empty map is what stops the transport upgrading to HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// outboundProxy how the destinations' requests find their proxy.  PROXY_URL sends all of them through it, except the
+// hosts in NO_PROXY, otherwise HTTPS_PROXY, HTTP_PROXY and NO_PROXY are honored as usual for VPCs that only have
+// egress through a corporate proxy.
+func outboundProxy() func(*http.Request) (*url.URL, error) {
+	value := getenv("PROXY_URL")
+	if value == "" {
+		return http.ProxyFromEnvironment
+	}
+	proxy, err := url.Parse(value)
+	if err == nil && (proxy.Scheme == "" || proxy.Host == "") {
+		err = fmt.Errorf("%q isn't a URL", value)
+	}
+	if err != nil {
+		configProblem("PROXY_URL", err)
+		return http.ProxyFromEnvironment
+	}
+
+	noProxy := splitList(getenv("NO_PROXY"))
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(strings.ToLower(req.URL.Hostname()), noProxy) {
+			return nil, nil
+		}
+		return proxy, nil
+	}
+}
+
+// bypassProxy whether the host is one NO_PROXY lists, either itself or a domain it's in.  Like ProxyFromEnvironment,
+// loopback addresses never go through the proxy.
+func bypassProxy(host string, noProxy []string) bool {
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	for _, entry := range noProxy {
+		// Ports don't matter, only which host
+		if name, _, err := net.SplitHostPort(entry); err == nil {
+			entry = name
+		}
+		entry = strings.ToLower(entry)
+		domain := strings.TrimPrefix(entry, "*")
+		switch {
+		case entry == "*":
+			return true
+		case strings.HasPrefix(domain, "."):
+			if strings.HasSuffix(host, domain) || host == domain[1:] {
+				return true
+			}
+		case host == entry || strings.HasSuffix(host, "."+entry):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import "testing"
+
+func TestBypassProxy(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		noProxy []string
+		bypass  bool
+	}{
+		{"nothing listed", "hooks.slack.com", nil, false},
+		{"localhost", "localhost", nil, true},
+		{"loopback", "127.0.0.1", nil, true},
+		{"loopback IPv6", "::1", nil, true},
+		{"wildcard", "hooks.slack.com", []string{"*"}, true},
+		{"exact host", "hooks.slack.com", []string{"hooks.slack.com"}, true},
+		{"subdomain of a host", "hooks.slack.com", []string{"slack.com"}, true},
+		{"leading dot", "hooks.slack.com", []string{".slack.com"}, true},
+		{"leading dot matches the domain", "slack.com", []string{".slack.com"}, true},
+		{"leading wildcard", "hooks.slack.com", []string{"*.slack.com"}, true},
+		{"with a port", "hooks.slack.com", []string{"hooks.slack.com:443"}, true},
+		{"case", "hooks.slack.com", []string{"Hooks.Slack.com"}, true},
+		{"suffix that isn't a domain", "notslack.com", []string{"slack.com"}, false},
+		{"another host", "outlook.office.com", []string{"slack.com", ".amazonaws.com"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if bypass := bypassProxy(test.host, test.noProxy); bypass != test.bypass {
+				t.Errorf("bypassProxy(%q, %q) = %t, want %t", test.host, test.noProxy, bypass, test.bypass)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// trustedAdvisorStatusColors attachment color for each Trusted Advisor check status
+var trustedAdvisorStatusColors = map[string]string{
+	"OK":    "good",
+	"WARN":  "warning",
+	"ERROR": "danger",
+}
+
+// TrustedAdvisorCheckDetail the detail of a Trusted Advisor check item refresh event
+type TrustedAdvisorCheckDetail struct {
+	CheckName       string            `json:"check-name"`
+	CheckItemDetail map[string]string `json:"check-item-detail"`
+	Status          string            `json:"status"`
+	ResourceID      string            `json:"resource_id"`
+	UUID            string            `json:"uuid"`
+}
+
+// trustedAdvisorMessage renders a Trusted Advisor check state change with the check, its status and the resources it
+// flagged, posted to TRUSTED_ADVISOR_CHANNEL when set
+func trustedAdvisorMessage(sns events.SNSEntity) (*OtherMessage, error) {
+	event := events.CloudWatchEvent{}
+	if err := json.Unmarshal([]byte(sns.Message), &event); err != nil {
+		return nil, err
+	}
+	detail := TrustedAdvisorCheckDetail{}
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		return nil, err
+	}
+
+	flagged := len(event.Resources)
+	if flagged == 0 && detail.ResourceID != "" {
+		flagged = 1
+	}
+	color, ok := trustedAdvisorStatusColors[detail.Status]
+	if !ok {
+		color = "#439FE0"
+	}
+
+	attachmentFields := []slack.AttachmentField{
+		{Title: "Status", Value: detail.Status, Short: true},
+		{Title: "Flagged Resources", Value: fmt.Sprintf("%d", flagged), Short: true},
+		{Title: "AccountID", Value: event.AccountID, Short: true},
+		{Title: "Region", Value: event.Region, Short: true},
+	}
+	if detail.ResourceID != "" {
+		attachmentFields = append(attachmentFields, slack.AttachmentField{Title: "Resource", Value: detail.ResourceID, Short: false})
+	}
+	names := []string{}
+	for name := range detail.CheckItemDetail {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value := detail.CheckItemDetail[name]; value != "" {
+			attachmentFields = append(attachmentFields, slack.AttachmentField{Title: name, Value: value, Short: true})
+		}
+	}
+
+	return &OtherMessage{Channel: trustedAdvisorChannel, Attachment: slack.Attachment{
+		Color:           color,
+		Title:           fmt.Sprintf(":white_check_mark: Trusted Advisor %s: %s", detail.CheckName, detail.Status),
+		Text:            fmt.Sprintf("<https://%s/trustedadvisor/home#/dashboard|Trusted Advisor>", consoleHost(regionPartition(event.Region))),
+		Ts:              time.Now().Unix(),
+		AttachmentField: attachmentFields,
+	}}, nil
+}
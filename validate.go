@@ -0,0 +1,131 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// configProblems what was wrong with the settings as they were read.  Each one has fallen back to its default, which
+// validateConfig reports rather than leaving it to be found on the first real alarm.
+var (
+	configProblems      []string
+	configProblemsMutex sync.Mutex
+)
+
+// configProblem logs a setting that couldn't be used, keeping it for validateConfig
+func configProblem(name string, err error) {
+	slog.Warn("Invalid setting", "setting", name, "error", err)
+	recordConfigProblem(fmt.Sprintf("%s: %s", name, err))
+}
+
+// recordConfigProblem keeps the problem for validateConfig
+func recordConfigProblem(problem string) {
+	configProblemsMutex.Lock()
+	defer configProblemsMutex.Unlock()
+	configProblems = append(configProblems, problem)
+}
+
+// takeConfigProblems the problems kept so far, clearing them when reset is set
+func takeConfigProblems(reset bool) []string {
+	configProblemsMutex.Lock()
+	defer configProblemsMutex.Unlock()
+	problems := append([]string{}, configProblems...)
+	if reset {
+		configProblems = nil
+	}
+	return problems
+}
+
+// reconfigure reads the settings again after the config file or AppConfig changed.  It's too late to fail by then, so
+// problems are only logged.
+func reconfigure() {
+	takeConfigProblems(true)
+	applyPreset()
+	configure()
+	if err := validateConfig(); err != nil {
+		slog.Error("Invalid configuration", "error", err)
+	}
+}
+
+// validateConfig the problems with the configuration, collected into one error listing all of them
+func validateConfig() error {
+	problems := takeConfigProblems(false)
+	problem := func(name string, format string, args ...interface{}) {
+		problems = append(problems, name+": "+fmt.Sprintf(format, args...))
+	}
+
+	webhook, botToken, teams := currentSlackWebhook(), currentSlackBotToken(), currentTeamsWebhook()
+	if webhook == "" && botToken == "" && len(slackWorkspaces) == 0 && len(destinations) == 0 {
+		problem("SLACK_WEBHOOK", "either it, SLACK_BOT_TOKEN, SLACK_WORKSPACES or DESTINATIONS has to be set")
+	}
+	if webhook != "" {
+		if err := validWebhook("SLACK_WEBHOOK", webhook); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	secretsMutex.Lock()
+	for _, name := range []string{"SLACK_FAILOVER_WEBHOOK", "ESCALATION_WEBHOOK"} {
+		if value := secretValues[name]; value != "" {
+			if err := validWebhook(name, value); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	secretsMutex.Unlock()
+	if botToken != "" && slackMonitorChannel == "" {
+		problem("SLACK_MONITOR_CHANNEL", "has to be set to post with SLACK_BOT_TOKEN")
+	}
+	if teams != "" {
+		if parsed, err := url.Parse(teams); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			problem("TEAMS_WEBHOOK", "isn't an https URL")
+		}
+	}
+
+	oneOf := func(name string, value string, allowed ...string) {
+		for _, option := range allowed {
+			if value == option {
+				return
+			}
+		}
+		problem(name, "%q isn't one of %s", value, strings.Join(allowed, ", "))
+	}
+	oneOf("PASSTHROUGH", passthrough, passthroughPretty, passthroughRaw, passthroughDrop)
+	oneOf("INSUFFICIENT_DATA_POLICY", insufficientDataPolicy, insufficientDataNotify, insufficientDataDrop, insufficientDataDowngrade, insufficientDataDelay)
+	oneOf("ACTIONS_DISABLED_POLICY", actionsDisabledPolicy, actionsDisabledNotify, actionsDisabledLabel, actionsDisabledSuppress)
+	if value := getenv("TRACING"); value != "" {
+		oneOf("TRACING", strings.ToLower(value), tracingXRay, tracingOTel)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration, %d problems:\n  - %s", len(problems), strings.Join(problems, "\n  - "))
+}
+
+// validWebhook checks the webhook is set and looks like a Slack incoming webhook URL
+func validWebhook(name string, webhook string) error {
+	if webhook == "" {
+		return fmt.Errorf("%s isn't set, set it to a Slack incoming webhook URL", name)
+	}
+	if parsed, err := url.Parse(webhook); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("%s isn't a valid Slack incoming webhook URL, it should look like https://hooks.slack.com/services/...", name)
+	}
+	return nil
+}
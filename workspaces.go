@@ -0,0 +1,168 @@
+// Copyright 2018 Jonathan Monette
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jmoney8080/go-gadget-slack"
+)
+
+// SlackWorkspace a Slack workspace besides the one SLACK_WEBHOOK and SLACK_BOT_TOKEN post to, configured by the
+// SLACK_WORKSPACES JSON object keyed by name.  Routes and severities send alarms to it with a workspace:channel
+// channel, e.g. "oncall:#pages".  It's posted to with either its webhook or its bot token, each of which can be a
+// reference like DESTINATIONS credentials.  Alarms posted to another workspace don't get the threads, reactions and
+// buttons the bot token's workspace does.
+type SlackWorkspace struct {
+	Webhook  string `json:"webhook,omitempty"`
+	BotToken string `json:"bot_token,omitempty"`
+}
+
+// parseSlackWorkspaces parses the SLACK_WORKSPACES setting
+func parseSlackWorkspaces(value string) (map[string]SlackWorkspace, error) {
+	parsed := map[string]SlackWorkspace{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, err
+	}
+	for name, workspace := range parsed {
+		if (workspace.Webhook == "") == (workspace.BotToken == "") {
+			return nil, fmt.Errorf("workspace %s needs either a webhook or a bot_token", name)
+		}
+	}
+	return parsed, nil
+}
+
+// splitChannel the workspace a workspace:channel channel names and the channel in it, an empty workspace being the
+// SLACK_WEBHOOK or SLACK_BOT_TOKEN one.  Slack channel names and IDs can't have a colon in them.
+func splitChannel(channel string) (string, string) {
+	if workspace, name, ok := strings.Cut(channel, ":"); ok {
+		return workspace, name
+	}
+	return "", channel
+}
+
+// validChannel an error when the channel names a workspace that isn't in SLACK_WORKSPACES
+func validChannel(channel string) error {
+	if workspace, _ := splitChannel(channel); workspace != "" {
+		if _, ok := slackWorkspaces[workspace]; !ok {
+			return fmt.Errorf("channel %s is in unknown workspace %q", channel, workspace)
+		}
+	}
+	return nil
+}
+
+// otherWorkspaces splits off the notifications routed to another workspace, by workspace
+func otherWorkspaces(notifications []*notification) ([]*notification, map[string][]*notification) {
+	own := []*notification{}
+	others := map[string][]*notification{}
+	for _, n := range notifications {
+		if workspace, _ := splitChannel(alarmChannel(n)); workspace != "" {
+			others[workspace] = append(others[workspace], n)
+		} else {
+			own = append(own, n)
+		}
+	}
+	return own, others
+}
+
+// sendWorkspaces posts each workspace's notifications to it, returning the last error
+func sendWorkspaces(ctx context.Context, others map[string][]*notification) error {
+	var sendErr error
+	for name, notifications := range others {
+		if err := slackWorkspaces[name].send(ctx, name, notifications); err != nil {
+			sendErr = err
+		}
+	}
+	return sendErr
+}
+
+// send posts the notifications to their channels in the workspace, marking the ones that fail so they're retried
+func (workspace SlackWorkspace) send(ctx context.Context, name string, notifications []*notification) error {
+	fail := func(n *notification, channel string, err error) {
+		slog.ErrorContext(ctx, "Sending notification failed", "destination", "slack:"+name+":"+channel, "error", err)
+		n.markFailed(err, "slack:"+name+":"+channel)
+	}
+
+	if workspace.BotToken != "" {
+		token, err := resolveCredentials(ctx, workspace.BotToken)
+		if err != nil {
+			err = fmt.Errorf("workspace %s: %s", name, err)
+			for _, n := range notifications {
+				_, channel := splitChannel(alarmChannel(n))
+				fail(n, channel, err)
+			}
+			return err
+		}
+		var sendErr error
+		for _, n := range notifications {
+			_, channel := splitChannel(alarmChannel(n))
+			ctx := withAlarms(ctx, n.cloudWatchAlarmEvent.AlarmARN)
+			message := SlackMessage{
+				Channel:     channel,
+				Attachments: []slack.Attachment{n.slackAttachment},
+				Metadata:    alarmMetadata(n.cloudWatchAlarmEvent, n.severity),
+			}
+			started := time.Now()
+			response := SlackAPIResponse{}
+			err := slackAPIWithToken(ctx, token, "chat.postMessage", message, &response)
+			if err == nil && !response.OK {
+				err = fmt.Errorf("slack chat.postMessage failed: %s", response.Error)
+			}
+			auditDelivery(ctx, "slack:"+name+":"+channel, message, response, err, started)
+			if err != nil {
+				fail(n, channel, err)
+				sendErr = err
+			}
+		}
+		return sendErr
+	}
+
+	webhook, err := resolveCredentials(ctx, workspace.Webhook)
+	if err != nil {
+		err = fmt.Errorf("workspace %s: %s", name, err)
+		for _, n := range notifications {
+			_, channel := splitChannel(alarmChannel(n))
+			fail(n, channel, err)
+		}
+		return err
+	}
+	client := &webhookClient{url: webhook}
+	byChannel := map[string][]*notification{}
+	for _, n := range notifications {
+		_, channel := splitChannel(alarmChannel(n))
+		byChannel[channel] = append(byChannel[channel], n)
+	}
+	var sendErr error
+	for channel, notifications := range byChannel {
+		for _, chunk := range chunkAttachments(attachments(notifications)) {
+			payload := slack.Payload{Channel: channel, Attachments: chunk}
+			started := time.Now()
+			resp, err := sendWebhook(ctx, client, payload)
+			auditDelivery(ctx, "slack-webhook:"+name+":"+channel, payload, resp, err, started)
+			if err != nil {
+				for _, n := range notifications {
+					fail(n, channel, err)
+				}
+				sendErr = err
+			}
+		}
+	}
+	return sendErr
+}